@@ -0,0 +1,50 @@
+package backoff
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff computes jittered exponential delays between reconnect attempts.
+type Backoff struct {
+	Min    time.Duration
+	Max    time.Duration
+	Factor float64
+
+	attempt int
+}
+
+// New creates a Backoff that doubles from min up to max.
+func New(min, max time.Duration) *Backoff {
+	return &Backoff{Min: min, Max: max, Factor: 2}
+}
+
+// Next returns the delay to wait before the next attempt and advances the attempt counter.
+// Half of the delay is fixed and the other half is random, so clients that drop at the
+// same moment don't all reconnect in lockstep.
+func (b *Backoff) Next() time.Duration {
+	d := float64(b.Min)
+	for i := 0; i < b.attempt && d < float64(b.Max); i++ {
+		d *= b.Factor
+	}
+	if d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	b.attempt++
+
+	half := time.Duration(d / 2)
+	if half <= 0 {
+		return time.Duration(d)
+	}
+	return half + rand.N(half)
+}
+
+// Attempt returns the number of delays handed out since the last reset.
+func (b *Backoff) Attempt() int {
+	return b.attempt
+}
+
+// Reset starts the delay sequence over, typically after a successful connection.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
@@ -0,0 +1,50 @@
+package bus
+
+import (
+	"sync"
+)
+
+// Bus is an in-process publish/subscribe hub that fans events out to every subscriber.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[chan Event]struct{}
+}
+
+// New creates an empty bus.
+func New() *Bus {
+	return &Bus{subs: make(map[chan Event]struct{})}
+}
+
+// Subscribe registers a consumer with room for buffer pending events. The returned
+// function unsubscribes and closes the channel.
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish delivers e to every subscriber. A subscriber whose buffer is full misses
+// the event rather than stalling the chat or EventSub connection that published it.
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package bus
+
+import (
+	"time"
+
+	"argus/services"
+)
+
+// Event is anything that can be published on the bus.
+type Event interface {
+	// Kind names the event type, for sinks that serialize events.
+	Kind() string
+}
+
+// ChatMessage is a message someone sent in chat.
+type ChatMessage struct {
+	ID          string            `json:"id"`
+	Channel     string            `json:"channel"`
+	UserLogin   string            `json:"user_login"`
+	DisplayName string            `json:"display_name"`
+	Color       string            `json:"color,omitempty"`
+	Badges      string            `json:"badges,omitempty"`
+	Text        string            `json:"text"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Time        time.Time         `json:"time"`
+	// Self is set on messages Argus sent itself.
+	Self bool `json:"self,omitempty"`
+}
+
+// UserNotice is a notice Twitch posts in chat for things like subs, resubs, raids and
+// announcements.
+type UserNotice struct {
+	Channel string `json:"channel"`
+	// Type is the notice's msg-id, such as sub, resub, raid or announcement.
+	Type string `json:"type"`
+	User string `json:"user"`
+	// SystemMessage is the text Twitch shows for the notice.
+	SystemMessage string `json:"system_message,omitempty"`
+	// Text is what the user wrote along with it, if anything.
+	Text string `json:"text,omitempty"`
+	// Color is the highlight color of an announcement, such as PRIMARY or BLUE.
+	Color string `json:"color,omitempty"`
+}
+
+// ClearChat is a ban, a timeout or, when User is empty, the whole chat being cleared.
+type ClearChat struct {
+	Channel string `json:"channel"`
+	User    string `json:"user,omitempty"`
+	// Duration is how long a timeout lasts. It is zero for bans.
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// ClearMessage is a single chat message being deleted.
+type ClearMessage struct {
+	Channel   string `json:"channel"`
+	User      string `json:"user"`
+	MessageID string `json:"message_id"`
+	Text      string `json:"text"`
+}
+
+// RoomState is a change to the chat modes of a channel, such as slow or emote-only mode.
+type RoomState struct {
+	Channel string `json:"channel"`
+	// Changes describes each mode that changed, such as "slow mode on (30s)".
+	Changes []string `json:"changes"`
+}
+
+// Notice is a message from the Twitch server, such as a failed login or a command result.
+type Notice struct {
+	Channel string `json:"channel,omitempty"`
+	// ID is the notice's msg-id, when Twitch sends one.
+	ID   string `json:"id,omitempty"`
+	Text string `json:"text"`
+}
+
+// ChatReply is a message Argus wants to send in answer to a chat message.
+type ChatReply struct {
+	Channel string `json:"channel"`
+	// ParentID is the ID of the message being answered.
+	ParentID string `json:"parent_id,omitempty"`
+	Text     string `json:"text"`
+}
+
+// Follow is a new follower.
+type Follow struct {
+	Channel string `json:"channel,omitempty"`
+	User    string `json:"user"`
+}
+
+// Raid is an incoming raid.
+type Raid struct {
+	Channel string `json:"channel,omitempty"`
+	From    string `json:"from"`
+	Viewers int    `json:"viewers"`
+}
+
+// Subscribe is a new subscription or, when Months is set, a resub.
+type Subscribe struct {
+	Channel string `json:"channel,omitempty"`
+	User    string `json:"user"`
+	Tier    string `json:"tier"`
+	Months  int    `json:"months,omitempty"`
+	Gift    bool   `json:"gift,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// GiftSub is a batch of subs gifted to the community.
+type GiftSub struct {
+	Channel   string `json:"channel,omitempty"`
+	User      string `json:"user"`
+	Total     int    `json:"total"`
+	Tier      string `json:"tier"`
+	Anonymous bool   `json:"anonymous,omitempty"`
+}
+
+// Cheer is a bits donation.
+type Cheer struct {
+	Channel   string `json:"channel,omitempty"`
+	User      string `json:"user"`
+	Bits      int    `json:"bits"`
+	Message   string `json:"message,omitempty"`
+	Anonymous bool   `json:"anonymous,omitempty"`
+}
+
+// Redemption is a channel point reward redemption.
+type Redemption struct {
+	Channel string `json:"channel,omitempty"`
+	User    string `json:"user"`
+	Reward  string `json:"reward"`
+	Cost    int    `json:"cost"`
+	Input   string `json:"input,omitempty"`
+}
+
+// Activity is any other channel activity, such as hype trains, polls, predictions,
+// ad breaks and shoutouts, already summarized as text.
+type Activity struct {
+	Channel string `json:"channel,omitempty"`
+	Type    string `json:"type"`
+	Text    string `json:"text"`
+}
+
+// TrackChanged is published when the playing track or its play state changes.
+type TrackChanged struct {
+	Data services.NowPlayingData `json:"data"`
+}
+
+// TrackProgress is published periodically while the same track keeps playing.
+type TrackProgress struct {
+	Data services.NowPlayingData `json:"data"`
+}
+
+// ConnectionState is published when a connection to Twitch changes state.
+type ConnectionState struct {
+	Service string `json:"service"`
+	State   string `json:"state"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ChannelOf returns the channel an event happened in, or "" for events that don't
+// belong to a channel or whose channel is unknown.
+func ChannelOf(event Event) string {
+	switch e := event.(type) {
+	case ChatMessage:
+		return e.Channel
+	case Follow:
+		return e.Channel
+	case Raid:
+		return e.Channel
+	case Subscribe:
+		return e.Channel
+	case GiftSub:
+		return e.Channel
+	case Cheer:
+		return e.Channel
+	case Redemption:
+		return e.Channel
+	case Activity:
+		return e.Channel
+	case UserNotice:
+		return e.Channel
+	case ClearChat:
+		return e.Channel
+	case ClearMessage:
+		return e.Channel
+	case RoomState:
+		return e.Channel
+	case Notice:
+		return e.Channel
+	case ChatReply:
+		return e.Channel
+	}
+	return ""
+}
+
+func (ChatMessage) Kind() string     { return "chat_message" }
+func (UserNotice) Kind() string      { return "user_notice" }
+func (ClearChat) Kind() string       { return "clear_chat" }
+func (ClearMessage) Kind() string    { return "clear_message" }
+func (RoomState) Kind() string       { return "room_state" }
+func (Notice) Kind() string          { return "notice" }
+func (ChatReply) Kind() string       { return "chat_reply" }
+func (Follow) Kind() string          { return "follow" }
+func (Raid) Kind() string            { return "raid" }
+func (Subscribe) Kind() string       { return "subscribe" }
+func (GiftSub) Kind() string         { return "gift_sub" }
+func (Cheer) Kind() string           { return "cheer" }
+func (Redemption) Kind() string      { return "redemption" }
+func (Activity) Kind() string        { return "activity" }
+func (TrackChanged) Kind() string    { return "track_changed" }
+func (TrackProgress) Kind() string   { return "track_progress" }
+func (ConnectionState) Kind() string { return "connection_state" }
@@ -0,0 +1,181 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"argus/helix"
+	"argus/history"
+	"argus/services"
+)
+
+// Built-in commands can be used once every builtinCooldown, and by each user once every
+// builtinUserCooldown. Custom commands default to the same.
+const (
+	builtinCooldown     = 5 * time.Second
+	builtinUserCooldown = 30 * time.Second
+)
+
+// SongCommand answers !song with the track that is playing now.
+func SongCommand(current func() services.NowPlayingData) Command {
+	return Command{
+		Name:           "song",
+		UserCooldown:   builtinUserCooldown,
+		GlobalCooldown: builtinCooldown,
+		Handler: func(inv Invocation) (string, error) {
+			data := current()
+			if data.Item == nil || data.Status == services.StatusStopped {
+				return "Nothing is playing right now.", nil
+			}
+			song := data.Item.Name
+			if artists := data.Item.ArtistNames(); artists != "" {
+				song = artists + " - " + song
+			}
+			if !data.IsPlaying {
+				return "Paused: " + song, nil
+			}
+			return "Now playing: " + song, nil
+		},
+	}
+}
+
+// LastSongCommand answers !lastsong with the track that played before the current one.
+func LastSongCommand(songs *history.History) Command {
+	return Command{
+		Name:           "lastsong",
+		UserCooldown:   builtinUserCooldown,
+		GlobalCooldown: builtinCooldown,
+		Handler: func(inv Invocation) (string, error) {
+			if entry, ok := songs.Last(); ok {
+				return "Last song: " + entry.String(), nil
+			}
+			return "No songs have played yet.", nil
+		},
+	}
+}
+
+// UptimeCommand answers !uptime with how long the channel it is used in has been live.
+func UptimeCommand(api *helix.Client) Command {
+	return Command{
+		Name:           "uptime",
+		UserCooldown:   builtinUserCooldown,
+		GlobalCooldown: builtinCooldown,
+		Handler: func(inv Invocation) (string, error) {
+			stream, err := api.StreamByLogin(strings.TrimPrefix(inv.Message.Channel, "#"))
+			if err != nil {
+				return "", err
+			}
+			if stream == nil {
+				return "The stream is offline.", nil
+			}
+			return "Live for " + formatUptime(time.Since(stream.StartedAt)), nil
+		},
+	}
+}
+
+// formatUptime formats d as hours and minutes, such as "2h 5m".
+func formatUptime(d time.Duration) string {
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	if hours == 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
+// TextCommand is a custom command from the commands file that replies with a template.
+type TextCommand struct {
+	Name string `json:"name"`
+	// Response is a text/template with .User, .Channel and .Args, the text after the command.
+	Response     string `json:"response"`
+	Permission   string `json:"permission,omitempty"`
+	Cooldown     string `json:"cooldown,omitempty"`
+	UserCooldown string `json:"user_cooldown,omitempty"`
+}
+
+// textCommandData is what custom command templates can use.
+type textCommandData struct {
+	User    string
+	Channel string
+	Args    string
+}
+
+// LoadTextCommands reads custom commands from a JSON file holding a list of TextCommand.
+// A missing file means there are no custom commands.
+func LoadTextCommands(path string) ([]Command, error) {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var defs []TextCommand
+	if err := json.Unmarshal(raw, &defs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	commands := make([]Command, 0, len(defs))
+	for _, def := range defs {
+		cmd, err := def.command()
+		if err != nil {
+			return nil, fmt.Errorf("%s: command %q: %w", path, def.Name, err)
+		}
+		commands = append(commands, cmd)
+	}
+	return commands, nil
+}
+
+// command turns the definition into a Command.
+func (def TextCommand) command() (Command, error) {
+	name := strings.ToLower(strings.TrimPrefix(def.Name, CommandPrefix))
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return Command{}, fmt.Errorf("invalid name")
+	}
+
+	permission, err := ParsePermission(def.Permission)
+	if err != nil {
+		return Command{}, err
+	}
+	cooldown, err := parseCooldown(def.Cooldown, builtinCooldown)
+	if err != nil {
+		return Command{}, fmt.Errorf("cooldown: %w", err)
+	}
+	userCooldown, err := parseCooldown(def.UserCooldown, builtinUserCooldown)
+	if err != nil {
+		return Command{}, fmt.Errorf("user_cooldown: %w", err)
+	}
+	response, err := template.New(name).Parse(def.Response)
+	if err != nil {
+		return Command{}, err
+	}
+
+	return Command{
+		Name:           name,
+		Permission:     permission,
+		UserCooldown:   userCooldown,
+		GlobalCooldown: cooldown,
+		Handler: func(inv Invocation) (string, error) {
+			var text strings.Builder
+			err := response.Execute(&text, textCommandData{
+				User:    inv.Message.DisplayName,
+				Channel: strings.TrimPrefix(inv.Message.Channel, "#"),
+				Args:    strings.Join(inv.Args, " "),
+			})
+			return text.String(), err
+		},
+	}, nil
+}
+
+// parseCooldown parses a duration such as "30s", using fallback when it is empty.
+func parseCooldown(s string, fallback time.Duration) (time.Duration, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -2,17 +2,18 @@ package chat
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"log"
 	"net"
-	"os"
-	"regexp"
 	"strings"
+	"sync"
+	"time"
 
-	"golang.org/x/term"
-
-	"argus/colors"
+	"argus/backoff"
+	"argus/bus"
 	"argus/config"
+	"argus/health"
 )
 
 // --- Configuration ---
@@ -28,130 +29,264 @@ var CLIENT_ID string
 // The channel to connect to, including the # prefix.
 var CHANNEL string
 
-// A regular expression to parse the username and message from an IRC PRIVMSG.
-var chatMessageRegex = regexp.MustCompile(`^:(\w+)!.*?PRIVMSG #\w+ :(.+)$`)
-
-// A regular expression to extract IRC tags.
-var ircTagRegex = regexp.MustCompile(`^@([^ ]+) `)
-
-// A regular expression to strip ANSI codes.
-var ansiStripRegex = regexp.MustCompile("[\u001B\u009B][[\\]()#;?]*(?:(?:(?:[a-zA-Z\\d]*(?:;[a-zA-Z\\d]*)*)?(?:[a-zA-Z\\d]+(?:;[a-zA-Z\\d]*)*)?[a-zA-Z])|(?:[a-zA-Z\\d]*(?:;[a-zA-Z\\d]*)*)?(?:[a-zA-Z\\d]+(?:;[a-zA-Z\\d]*)*)?[a-zA-Z\u0080-\u009F])")
-
 // --- IRC Chat Configuration ---
 const (
 	IRC_SERVER = "irc.chat.twitch.tv"
 	IRC_PORT   = "6667"
 )
 
-func Connect(cfg config.Config) {
-	conn, err := net.Dial("tcp", IRC_SERVER+":"+IRC_PORT)
+// State describes where the IRC session currently is in its lifecycle.
+type State int
+
+const (
+	StateDisconnected State = iota
+	StateConnecting
+	StateConnected
+	StateReconnecting
+)
+
+func (s State) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	default:
+		return "disconnected"
+	}
+}
+
+// healthName is the key chat reports its status under.
+const healthName = "chat"
+
+// Twitch sends a PING roughly every five minutes, so a silent socket past this point is dead.
+const readTimeout = 6 * time.Minute
+
+// errReconnectRequested is returned when Twitch asks us to move to a new connection.
+var errReconnectRequested = errors.New("server requested reconnect")
+
+// Client is a supervised Twitch IRC session that reconnects with backoff whenever the connection drops.
+type Client struct {
+	cfg config.Config
+	bus *bus.Bus
+
+	// Addr is the IRC server to dial. It defaults to the Twitch IRC endpoint.
+	Addr string
+
+	backoff *backoff.Backoff
+
+	mu    sync.Mutex
+	state State
+	conn  net.Conn
+
+	// roomState remembers the last chat modes seen per channel, so only changes are reported.
+	roomState map[string]map[string]string
+	// userState holds the USERSTATE tags of each channel, which carry our own badges.
+	userState map[string]map[string]string
+	// messageChannels maps recent message IDs to their channel, oldest first in messageOrder.
+	messageChannels map[string]string
+	messageOrder    []string
+
+	limiter rateLimiter
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewClient creates a chat client for the configured channels that publishes what it receives on b.
+func NewClient(cfg config.Config, b *bus.Bus) *Client {
+	return &Client{
+		cfg:             cfg,
+		bus:             b,
+		Addr:            IRC_SERVER + ":" + IRC_PORT,
+		backoff:         backoff.New(time.Second, 2*time.Minute),
+		roomState:       make(map[string]map[string]string),
+		userState:       make(map[string]map[string]string),
+		messageChannels: make(map[string]string),
+		done:            make(chan struct{}),
+	}
+}
+
+// Connect runs a chat client until the process exits.
+func Connect(cfg config.Config, b *bus.Bus) {
+	NewClient(cfg, b).Run()
+}
+
+// State returns the current connection state.
+func (c *Client) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// Run keeps the IRC session alive until Close is called. It also sends every ChatReply
+// published on the bus.
+func (c *Client) Run() {
+	go c.sendReplies()
+
+	for {
+		err := c.session()
+
+		select {
+		case <-c.done:
+			c.setState(StateDisconnected, nil)
+			return
+		default:
+		}
+
+		if errors.Is(err, errReconnectRequested) {
+			// Twitch is about to restart the server we're on; hop over right away.
+			log.Printf("Twitch requested an IRC reconnect")
+			c.setState(StateReconnecting, err)
+			continue
+		}
+
+		delay := c.backoff.Next()
+		log.Printf("IRC Connection lost or closed: %v. Reconnecting in %s", err, delay.Round(time.Millisecond))
+		c.setState(StateReconnecting, err)
+
+		select {
+		case <-c.done:
+			c.setState(StateDisconnected, nil)
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Close stops the client and drops the current connection.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.mu.Lock()
+		if c.conn != nil {
+			c.conn.Close()
+		}
+		c.mu.Unlock()
+	})
+}
+
+// session dials the server, registers, and reads lines until the connection fails.
+func (c *Client) session() error {
+	c.setState(StateConnecting, nil)
+
+	conn, err := net.DialTimeout("tcp", c.Addr, 10*time.Second)
 	if err != nil {
-		log.Fatalf("Error connecting to Twitch IRC: %v", err)
+		return fmt.Errorf("dial %s: %w", c.Addr, err)
 	}
 	defer conn.Close()
 
-	reader := bufio.NewReader(conn)
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+
+	// Close may have raced with the dial; don't start a session nobody wants.
+	select {
+	case <-c.done:
+		return nil
+	default:
+	}
 
-	fmt.Println("\n-------------------- Twitch Chat --------------------")
-	// Request IRCv3 tags capability to get user badges.
-	fmt.Fprintf(conn, "CAP REQ :twitch.tv/tags\r\n")
+	// Request IRCv3 tags to get user badges, and commands for notices, bans and chat modes.
+	fmt.Fprintf(conn, "CAP REQ :twitch.tv/tags twitch.tv/commands\r\n")
 	// The IRC connection requires the `oauth:` prefix.
-	fmt.Fprintf(conn, "PASS oauth:%s\r\n", cfg.OAuthToken)
-	fmt.Fprintf(conn, "NICK %s\r\n", cfg.Nick)
-	fmt.Fprintf(conn, "JOIN %s\r\n", cfg.Channel)
-	log.Printf("Joined IRC channel %s", cfg.Channel)
+	fmt.Fprintf(conn, "PASS oauth:%s\r\n", c.cfg.OAuthToken)
+	fmt.Fprintf(conn, "NICK %s\r\n", c.cfg.Nick)
+	names := make([]string, len(c.cfg.Channels))
+	for i, channel := range c.cfg.Channels {
+		names[i] = channel.Name
+	}
+	fmt.Fprintf(conn, "JOIN %s\r\n", strings.Join(names, ","))
 
+	reader := bufio.NewReader(conn)
 	for {
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
 		line, err := reader.ReadString('\n')
 		if err != nil {
-			log.Printf("IRC Connection lost or closed: %v", err)
-			return
+			return err
 		}
-		line = strings.TrimSpace(line)
+		health.Touch(healthName)
 
-		if strings.HasPrefix(line, "PING") {
-			fmt.Fprintf(conn, "PONG :tmi.twitch.tv\r\n")
+		msg, err := Parse(line)
+		if err != nil {
+			if c.cfg.ShowLogs {
+				log.Printf("Skipping IRC line %q: %v", line, err)
+			}
+			continue
 		}
 
-		parts := strings.SplitN(line, "PRIVMSG", 2)
-		if len(parts) == 2 {
-			tagString := ircTagRegex.FindStringSubmatch(parts[0])
-			username := ""
-			message := strings.TrimSpace(parts[1][strings.Index(parts[1], ":")+1:])
-
-			if tagString != nil {
-				tags := parseTags(tagString[1])
-				username = tags["display-name"]
-				if username == "" {
-					username = tags["login"]
-				}
-				color := getColorByRole(tags["badges"])
-
-				// Get the terminal width and wrap the message
-				width, _, err := term.GetSize(int(os.Stdout.Fd()))
-				if err == nil && width > 0 {
-					prefix := fmt.Sprintf(" [CHAT] %s[%s]%s: ", color, username, colors.ColorReset)
-					prefixLen := len(stripAnsiCodes(prefix))
-					wrappedMessage := wrapMessage(message, width-prefixLen, prefixLen)
-					fmt.Printf("%s\n", prefix+wrappedMessage)
-				} else {
-					fmt.Printf(" [CHAT] %s[%s]%s: %s\n", color, username, colors.ColorReset, message)
-				}
-			} else {
-				// Fallback for messages without tags
-				matches := chatMessageRegex.FindStringSubmatch(line)
-				if len(matches) == 3 {
-					username = matches[1]
-					fmt.Printf(" [CHAT] %s[%s]%s: %s\n", colors.ColorTwitchPurple, username, colors.ColorReset, message)
-				}
+		switch msg.Command {
+		case "PING":
+			fmt.Fprintf(conn, "PONG :%s\r\n", msg.Trailing)
+		case "001":
+			// The server accepted our credentials, so the next drop starts backing off from scratch.
+			c.backoff.Reset()
+		case "JOIN":
+			c.setState(StateConnected, nil)
+			log.Printf("Joined IRC channel %s", msg.Param(0))
+		case "RECONNECT":
+			return errReconnectRequested
+		case "PRIVMSG":
+			c.handlePrivmsg(msg)
+		case "USERNOTICE":
+			c.handleUserNotice(msg)
+		case "CLEARCHAT":
+			c.handleClearChat(msg)
+		case "CLEARMSG":
+			c.handleClearMsg(msg)
+		case "USERSTATE":
+			c.handleUserState(msg)
+		case "ROOMSTATE":
+			c.handleRoomState(msg)
+		case "NOTICE":
+			if err := c.handleNotice(msg); err != nil {
+				return err
 			}
 		}
 	}
 }
 
-func wrapMessage(message string, width int, prefixLen int) string {
-	var builder strings.Builder
-	words := strings.Fields(message)
-	if len(words) == 0 {
-		return ""
-	}
+func (c *Client) setState(state State, err error) {
+	c.mu.Lock()
+	changed := c.state != state
+	c.state = state
+	c.mu.Unlock()
 
-	currentLineLen := 0
-	for i, word := range words {
-		if currentLineLen+len(word)+1 > width {
-			builder.WriteString("\n" + strings.Repeat(" ", prefixLen))
-			currentLineLen = 0
-		}
-		builder.WriteString(word)
-		if i < len(words)-1 {
-			builder.WriteString(" ")
-			currentLineLen += len(word) + 1
-		} else {
-			currentLineLen += len(word)
-		}
+	if !changed {
+		return
+	}
+	health.Set(healthName, state.String(), err)
+	if c.cfg.ShowLogs {
+		log.Printf("IRC connection state: %s", state)
 	}
-	return builder.String()
-}
 
-func stripAnsiCodes(str string) string {
-	return ansiStripRegex.ReplaceAllString(str, "")
+	event := bus.ConnectionState{Service: healthName, State: state.String()}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	c.bus.Publish(event)
 }
 
-func getColorByRole(badges string) string {
-	if strings.Contains(badges, "moderator") || strings.Contains(badges, "broadcaster") {
-		return colors.ColorRed
+// handlePrivmsg publishes a chat message on the bus.
+func (c *Client) handlePrivmsg(m Message) {
+	msg := bus.ChatMessage{
+		Channel:     m.Param(0),
+		UserLogin:   m.Prefix.Nick,
+		DisplayName: m.Prefix.Nick,
+		Text:        m.Trailing,
+		Tags:        m.Tags,
+		Time:        time.Now(),
 	}
-	return colors.ColorTwitchPurple
-}
 
-func parseTags(tagString string) map[string]string {
-	tags := make(map[string]string)
-	pairs := strings.SplitSeq(tagString, ";")
-	for pair := range pairs {
-		kv := strings.SplitN(pair, "=", 2)
-		if len(kv) == 2 {
-			tags[kv[0]] = kv[1]
-		}
+	if m.Tags != nil {
+		msg.ID = m.Tags["id"]
+		msg.Color = m.Tags["color"]
+		msg.Badges = m.Tags["badges"]
+		msg.DisplayName = displayName(m)
 	}
-	return tags
+
+	c.trackMessage(msg.ID, msg.Channel)
+	c.bus.Publish(msg)
 }
@@ -0,0 +1,204 @@
+package chat
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"argus/backoff"
+	"argus/bus"
+	"argus/config"
+)
+
+// fakeIRC is a local stand-in for the Twitch IRC server.
+type fakeIRC struct {
+	t        *testing.T
+	listener net.Listener
+}
+
+func newFakeIRC(t *testing.T) *fakeIRC {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return &fakeIRC{t: t, listener: listener}
+}
+
+// accept waits for the client to connect and returns the connection with the
+// registration lines it sent.
+func (s *fakeIRC) accept(timeout time.Duration) (net.Conn, []string) {
+	s.t.Helper()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := s.listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	var conn net.Conn
+	select {
+	case conn = <-accepted:
+	case <-time.After(timeout):
+		s.t.Fatalf("client did not connect within %s", timeout)
+	}
+	s.t.Cleanup(func() { conn.Close() })
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	reader := bufio.NewReader(conn)
+	var lines []string
+	for range 4 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			s.t.Fatalf("reading registration: %v", err)
+		}
+		lines = append(lines, strings.TrimRight(line, "\r\n"))
+	}
+	conn.SetReadDeadline(time.Time{})
+	return conn, lines
+}
+
+func newTestClient(t *testing.T, addr string, b *bus.Bus, retry *backoff.Backoff) *Client {
+	t.Helper()
+	cfg := config.Config{
+		Nick:       "argus",
+		OAuthToken: "secret",
+		Channel:    "#argus",
+		Channels:   []config.Channel{{Name: "#argus"}},
+	}
+	c := NewClient(cfg, b)
+	c.Addr = addr
+	c.backoff = retry
+	t.Cleanup(c.Close)
+	return c
+}
+
+var wantRegistration = []string{
+	"CAP REQ :twitch.tv/tags twitch.tv/commands",
+	"PASS oauth:secret",
+	"NICK argus",
+	"JOIN #argus",
+}
+
+// waitForState waits until the client publishes want on the bus.
+func waitForState(t *testing.T, events <-chan bus.Event, want State) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if e, ok := event.(bus.ConnectionState); ok && e.State == want.String() {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("state %s was never reported", want)
+		}
+	}
+}
+
+func TestClientReregistersAfterDrop(t *testing.T) {
+	server := newFakeIRC(t)
+	b := bus.New()
+	events, _ := b.Subscribe(64)
+	c := newTestClient(t, server.listener.Addr().String(), b, backoff.New(10*time.Millisecond, 20*time.Millisecond))
+	go c.Run()
+
+	conn, lines := server.accept(2 * time.Second)
+	if !slices.Equal(lines, wantRegistration) {
+		t.Fatalf("first registration = %q, want %q", lines, wantRegistration)
+	}
+	waitForState(t, events, StateConnecting)
+	fmt.Fprintf(conn, ":argus!argus@argus.tmi.twitch.tv JOIN #argus\r\n")
+	waitForState(t, events, StateConnected)
+
+	conn.Close()
+	waitForState(t, events, StateReconnecting)
+
+	_, lines = server.accept(2 * time.Second)
+	if !slices.Equal(lines, wantRegistration) {
+		t.Fatalf("registration after drop = %q, want %q", lines, wantRegistration)
+	}
+}
+
+func TestClientReconnectCommandSkipsBackoff(t *testing.T) {
+	server := newFakeIRC(t)
+	b := bus.New()
+	events, _ := b.Subscribe(64)
+	// A backoff this long would fail the test if RECONNECT waited for it.
+	c := newTestClient(t, server.listener.Addr().String(), b, backoff.New(time.Hour, time.Hour))
+	go c.Run()
+
+	conn, _ := server.accept(2 * time.Second)
+	fmt.Fprintf(conn, ":argus!argus@argus.tmi.twitch.tv JOIN #argus\r\n")
+	waitForState(t, events, StateConnected)
+
+	fmt.Fprintf(conn, ":tmi.twitch.tv RECONNECT\r\n")
+	waitForState(t, events, StateReconnecting)
+
+	_, lines := server.accept(2 * time.Second)
+	if !slices.Equal(lines, wantRegistration) {
+		t.Fatalf("registration after RECONNECT = %q, want %q", lines, wantRegistration)
+	}
+}
+
+func TestReplyRejectsInvalidMessageID(t *testing.T) {
+	c := newTestClient(t, "127.0.0.1:0", bus.New(), backoff.New(time.Second, time.Second))
+	for _, id := range []string{"", "abc def", "x;reply-parent-msg-id=1", "1 PRIVMSG #argus :hi", "B34CCFC7"} {
+		if err := c.Reply(id, "hello"); err == nil || errors.Is(err, ErrNotConnected) {
+			t.Errorf("Reply(%q) error = %v, want an invalid ID error", id, err)
+		}
+	}
+	if err := c.Reply("b34ccfc7-4977-403a-8a94-33c6bac34fb8", "hello"); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Reply(valid ID) error = %v, want %v", err, ErrNotConnected)
+	}
+}
+
+func TestRepliesQueueWhileRateLimited(t *testing.T) {
+	server := newFakeIRC(t)
+	b := bus.New()
+	events, _ := b.Subscribe(64)
+	c := newTestClient(t, server.listener.Addr().String(), b, backoff.New(time.Second, time.Second))
+	c.cfg.ChatVerified = true
+	go c.Run()
+
+	conn, _ := server.accept(2 * time.Second)
+	fmt.Fprintf(conn, ":argus!argus@argus.tmi.twitch.tv JOIN #argus\r\n")
+	waitForState(t, events, StateConnected)
+
+	// Use up the rate limit for a moment, so the first reply waits while the rest arrive
+	// along with enough other events to overflow any subscriber buffer.
+	c.limiter.mu.Lock()
+	for range verifiedLimit {
+		c.limiter.sent = append(c.limiter.sent, time.Now().Add(500*time.Millisecond-rateWindow))
+	}
+	c.limiter.mu.Unlock()
+
+	// They come in bursts smaller than a subscriber buffer, as a busy chat would send them.
+	const replies = 200
+	for i := range replies {
+		b.Publish(bus.ChatReply{Channel: "#argus", Text: fmt.Sprintf("reply %d", i)})
+		b.Publish(bus.ChatMessage{Channel: "#argus", Text: "noise"})
+		if i%10 == 9 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	for i := range replies {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reply %d was never sent: %v", i, err)
+		}
+		if want := fmt.Sprintf("PRIVMSG #argus :reply %d\r\n", i); line != want {
+			t.Fatalf("line %d = %q, want %q", i, line, want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package chat
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"argus/bus"
+)
+
+// CommandPrefix starts every chat command.
+const CommandPrefix = "!"
+
+// Permission is who may use a command. Each level includes everyone above it.
+type Permission int
+
+const (
+	PermissionEveryone Permission = iota
+	PermissionSubscriber
+	PermissionVIP
+	PermissionModerator
+	PermissionBroadcaster
+)
+
+func (p Permission) String() string {
+	switch p {
+	case PermissionSubscriber:
+		return "subscriber"
+	case PermissionVIP:
+		return "vip"
+	case PermissionModerator:
+		return "moderator"
+	case PermissionBroadcaster:
+		return "broadcaster"
+	default:
+		return "everyone"
+	}
+}
+
+// ParsePermission parses a permission level such as "everyone" or "moderator".
+func ParsePermission(s string) (Permission, error) {
+	for p := PermissionEveryone; p <= PermissionBroadcaster; p++ {
+		if strings.EqualFold(s, p.String()) {
+			return p, nil
+		}
+	}
+	switch strings.ToLower(s) {
+	case "", "all":
+		return PermissionEveryone, nil
+	case "sub":
+		return PermissionSubscriber, nil
+	case "mod":
+		return PermissionModerator, nil
+	}
+	return 0, fmt.Errorf("unknown permission %q", s)
+}
+
+// permissionOf returns the highest permission level the sender of msg has, based on their badges.
+func permissionOf(msg bus.ChatMessage) Permission {
+	badges := make(map[string]bool)
+	for badge := range strings.SplitSeq(msg.Badges, ",") {
+		name, _, _ := strings.Cut(badge, "/")
+		badges[name] = true
+	}
+
+	switch {
+	case badges["broadcaster"]:
+		return PermissionBroadcaster
+	case badges["moderator"] || msg.Tags["mod"] == "1":
+		return PermissionModerator
+	case badges["vip"]:
+		return PermissionVIP
+	case badges["subscriber"] || badges["founder"] || msg.Tags["subscriber"] == "1":
+		return PermissionSubscriber
+	default:
+		return PermissionEveryone
+	}
+}
+
+// Invocation is a single use of a command in chat.
+type Invocation struct {
+	Message bus.ChatMessage
+	// Name is the command name without the prefix, in lower case.
+	Name string
+	Args []string
+}
+
+// Command is a chat command such as !song.
+type Command struct {
+	// Name is the command name without the prefix.
+	Name string
+	// Permission is the lowest level allowed to use the command.
+	Permission Permission
+	// UserCooldown is how long each user has to wait before using the command again.
+	UserCooldown time.Duration
+	// GlobalCooldown is how long anyone has to wait after the command was used.
+	GlobalCooldown time.Duration
+	// Handler returns the reply to send. An empty reply sends nothing.
+	Handler func(inv Invocation) (string, error)
+}
+
+// Router answers chat commands published on the bus.
+type Router struct {
+	// Channels limits the channels commands are answered in. Empty answers in every channel.
+	Channels []string
+
+	mu       sync.Mutex
+	commands map[string]Command
+	// lastUsed and lastUsedBy record when each command was last used, overall and per user.
+	lastUsed   map[string]time.Time
+	lastUsedBy map[string]map[string]time.Time
+}
+
+// NewRouter creates a router without any commands.
+func NewRouter() *Router {
+	return &Router{
+		commands:   make(map[string]Command),
+		lastUsed:   make(map[string]time.Time),
+		lastUsedBy: make(map[string]map[string]time.Time),
+	}
+}
+
+// Register adds commands to the router, replacing any with the same name.
+func (r *Router) Register(commands ...Command) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, cmd := range commands {
+		r.commands[strings.ToLower(cmd.Name)] = cmd
+	}
+}
+
+// Commands returns the names of all registered commands, sorted.
+func (r *Router) Commands() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Sorted(maps.Keys(r.commands))
+}
+
+// Run answers commands in chat messages published on b until the bus subscription is
+// closed. Replies are published as ChatReply for the chat client to send.
+func (r *Router) Run(b *bus.Bus) {
+	events, _ := b.Subscribe(64)
+	for event := range events {
+		msg, ok := event.(bus.ChatMessage)
+		// Messages Argus sent itself are skipped so a reply can never trigger another command.
+		if !ok || msg.Self {
+			continue
+		}
+		if len(r.Channels) > 0 && !slices.Contains(r.Channels, msg.Channel) {
+			continue
+		}
+
+		inv, ok := ParseInvocation(msg)
+		if !ok {
+			continue
+		}
+		// Handlers may call out to Twitch, so don't hold up the rest of chat.
+		go func() {
+			if reply := r.Handle(inv); reply != "" {
+				b.Publish(bus.ChatReply{Channel: msg.Channel, ParentID: msg.ID, Text: reply})
+			}
+		}()
+	}
+}
+
+// ParseInvocation parses "!cmd args" from a chat message. When the message is a reply,
+// Twitch puts "@user" in front of the text, which is skipped.
+func ParseInvocation(msg bus.ChatMessage) (Invocation, bool) {
+	fields := strings.Fields(msg.Text)
+	if len(fields) > 0 && msg.Tags["reply-parent-msg-id"] != "" && strings.HasPrefix(fields[0], "@") {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], CommandPrefix) {
+		return Invocation{}, false
+	}
+
+	name := strings.ToLower(strings.TrimPrefix(fields[0], CommandPrefix))
+	if name == "" {
+		return Invocation{}, false
+	}
+	return Invocation{Message: msg, Name: name, Args: fields[1:]}, true
+}
+
+// Handle runs the command inv names, if it exists, the sender is allowed to use it and
+// it is not cooling down. It returns the reply to send, if any.
+func (r *Router) Handle(inv Invocation) string {
+	user := inv.Message.UserLogin
+	level := permissionOf(inv.Message)
+
+	r.mu.Lock()
+	cmd, ok := r.commands[inv.Name]
+	if !ok || level < cmd.Permission {
+		r.mu.Unlock()
+		return ""
+	}
+
+	// Moderators and the broadcaster skip cooldowns.
+	now := time.Now()
+	if level < PermissionModerator {
+		if now.Sub(r.lastUsed[inv.Name]) < cmd.GlobalCooldown || now.Sub(r.lastUsedBy[inv.Name][user]) < cmd.UserCooldown {
+			r.mu.Unlock()
+			return ""
+		}
+	}
+	r.lastUsed[inv.Name] = now
+	if r.lastUsedBy[inv.Name] == nil {
+		r.lastUsedBy[inv.Name] = make(map[string]time.Time)
+	}
+	r.lastUsedBy[inv.Name][user] = now
+	r.mu.Unlock()
+
+	reply, err := cmd.Handler(inv)
+	if err != nil {
+		log.Printf("Error running command %s%s: %v", CommandPrefix, inv.Name, err)
+		return ""
+	}
+	return reply
+}
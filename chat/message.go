@@ -0,0 +1,126 @@
+package chat
+
+import (
+	"errors"
+	"strings"
+)
+
+// Message is a single IRC line with IRCv3 tags, as sent by Twitch.
+type Message struct {
+	// Tags holds the unescaped IRCv3 tags, or nil when the line has none.
+	Tags map[string]string
+	// Prefix says who sent the message.
+	Prefix Prefix
+	// Command is the command word or numeric reply, such as PRIVMSG or 001.
+	Command string
+	// Params holds the middle parameters, without the trailing one.
+	Params []string
+	// Trailing is the final parameter that follows " :", which may contain spaces.
+	Trailing string
+}
+
+// Prefix is the source of a message. Messages from the server itself only have Host set.
+type Prefix struct {
+	Nick string
+	User string
+	Host string
+}
+
+// Param returns the i-th middle parameter, or "" if there are fewer.
+func (m Message) Param(i int) string {
+	if i < len(m.Params) {
+		return m.Params[i]
+	}
+	return ""
+}
+
+// Parse parses a raw IRC line such as
+// "@badges=moderator/1;color=#FF0000 :nick!nick@nick.tmi.twitch.tv PRIVMSG #channel :hello".
+func Parse(line string) (Message, error) {
+	var msg Message
+	line = strings.TrimRight(line, "\r\n")
+
+	if rest, ok := strings.CutPrefix(line, "@"); ok {
+		var tags string
+		tags, line, _ = strings.Cut(rest, " ")
+		msg.Tags = parseTags(tags)
+	}
+	line = strings.TrimLeft(line, " ")
+
+	if rest, ok := strings.CutPrefix(line, ":"); ok {
+		var prefix string
+		prefix, line, _ = strings.Cut(rest, " ")
+		msg.Prefix = parsePrefix(prefix)
+	}
+	line = strings.TrimLeft(line, " ")
+
+	line, msg.Trailing, _ = strings.Cut(line, " :")
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return Message{}, errors.New("irc: missing command")
+	}
+	msg.Command = strings.ToUpper(fields[0])
+	msg.Params = fields[1:]
+	return msg, nil
+}
+
+// parseTags splits and unescapes an IRCv3 tag string without the leading "@".
+func parseTags(tagString string) map[string]string {
+	tags := make(map[string]string)
+	for pair := range strings.SplitSeq(tagString, ";") {
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		tags[key] = unescapeTag(value)
+	}
+	return tags
+}
+
+// unescapeTag reverses IRCv3 tag value escaping: \: is ";", \s is a space, \\ is a
+// backslash and \r and \n are line breaks. Any other escaped character stands for itself.
+func unescapeTag(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		if i == len(value) {
+			// A trailing lone backslash is dropped.
+			break
+		}
+		switch value[i] {
+		case ':':
+			b.WriteByte(';')
+		case 's':
+			b.WriteByte(' ')
+		case 'r':
+			b.WriteByte('\r')
+		case 'n':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// parsePrefix splits "nick!user@host". A prefix without "!" or "@" is a server name.
+func parsePrefix(prefix string) Prefix {
+	if !strings.ContainsAny(prefix, "!@") {
+		return Prefix{Host: prefix}
+	}
+
+	var p Prefix
+	rest, host, _ := strings.Cut(prefix, "@")
+	p.Host = host
+	p.Nick, p.User, _ = strings.Cut(rest, "!")
+	return p
+}
@@ -0,0 +1,131 @@
+package chat
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want Message
+	}{
+		{
+			name: "privmsg with escaped tags",
+			line: `@badge-info=;badges=broadcaster/1;color=#0D4200;display-name=Ronni;emotes=;id=b34ccfc7-4977-403a-8a94-33c6bac34fb8;mod=0;room-id=713936733;system-msg=Ronni\shas\ssubscribed\:\sthanks\\no;tmi-sent-ts=1642696567751;user-id=713936733;msg-param-note=ends\ :ronni!ronni@ronni.tmi.twitch.tv PRIVMSG #ronni :Kappa Keepo Kappa` + "\r\n",
+			want: Message{
+				Tags: map[string]string{
+					"badge-info":     "",
+					"badges":         "broadcaster/1",
+					"color":          "#0D4200",
+					"display-name":   "Ronni",
+					"emotes":         "",
+					"id":             "b34ccfc7-4977-403a-8a94-33c6bac34fb8",
+					"mod":            "0",
+					"room-id":        "713936733",
+					"system-msg":     `Ronni has subscribed; thanks\no`,
+					"tmi-sent-ts":    "1642696567751",
+					"user-id":        "713936733",
+					"msg-param-note": "ends",
+				},
+				Prefix:   Prefix{Nick: "ronni", User: "ronni", Host: "ronni.tmi.twitch.tv"},
+				Command:  "PRIVMSG",
+				Params:   []string{"#ronni"},
+				Trailing: "Kappa Keepo Kappa",
+			},
+		},
+		{
+			name: "privmsg whose text looks like a command",
+			line: "@color=;display-name=Foo :foo!foo@foo.tmi.twitch.tv PRIVMSG #bar :PRIVMSG #baz :not a real command",
+			want: Message{
+				Tags:     map[string]string{"color": "", "display-name": "Foo"},
+				Prefix:   Prefix{Nick: "foo", User: "foo", Host: "foo.tmi.twitch.tv"},
+				Command:  "PRIVMSG",
+				Params:   []string{"#bar"},
+				Trailing: "PRIVMSG #baz :not a real command",
+			},
+		},
+		{
+			name: "server ping",
+			line: "PING :tmi.twitch.tv\r\n",
+			want: Message{Command: "PING", Trailing: "tmi.twitch.tv"},
+		},
+		{
+			name: "welcome numeric",
+			line: ":tmi.twitch.tv 001 argus :Welcome, GLHF!",
+			want: Message{
+				Prefix:   Prefix{Host: "tmi.twitch.tv"},
+				Command:  "001",
+				Params:   []string{"argus"},
+				Trailing: "Welcome, GLHF!",
+			},
+		},
+		{
+			name: "usernotice",
+			line: `@badges=staff/1,broadcaster/1;display-name=ronni;login=ronni;msg-id=resub;msg-param-cumulative-months=6;system-msg=ronni\shas\ssubscribed\sfor\s6\smonths!;user-id=87654321 :tmi.twitch.tv USERNOTICE #dallas :Great stream -- keep it up!`,
+			want: Message{
+				Tags: map[string]string{
+					"badges":                      "staff/1,broadcaster/1",
+					"display-name":                "ronni",
+					"login":                       "ronni",
+					"msg-id":                      "resub",
+					"msg-param-cumulative-months": "6",
+					"system-msg":                  "ronni has subscribed for 6 months!",
+					"user-id":                     "87654321",
+				},
+				Prefix:   Prefix{Host: "tmi.twitch.tv"},
+				Command:  "USERNOTICE",
+				Params:   []string{"#dallas"},
+				Trailing: "Great stream -- keep it up!",
+			},
+		},
+		{
+			name: "clearchat with empty trailing",
+			line: "@room-id=12345678;tmi-sent-ts=1642715756806 :tmi.twitch.tv CLEARCHAT #dallas :",
+			want: Message{
+				Tags:    map[string]string{"room-id": "12345678", "tmi-sent-ts": "1642715756806"},
+				Prefix:  Prefix{Host: "tmi.twitch.tv"},
+				Command: "CLEARCHAT",
+				Params:  []string{"#dallas"},
+			},
+		},
+		{
+			name: "no tags",
+			line: ":argus!argus@argus.tmi.twitch.tv join #dallas",
+			want: Message{
+				Prefix:  Prefix{Nick: "argus", User: "argus", Host: "argus.tmi.twitch.tv"},
+				Command: "JOIN",
+				Params:  []string{"#dallas"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if (got.Tags == nil) != (tt.want.Tags == nil) || !maps.Equal(got.Tags, tt.want.Tags) {
+				t.Errorf("Tags = %q, want %q", got.Tags, tt.want.Tags)
+			}
+			if got.Prefix != tt.want.Prefix {
+				t.Errorf("Prefix = %+v, want %+v", got.Prefix, tt.want.Prefix)
+			}
+			if got.Command != tt.want.Command || !slices.Equal(got.Params, tt.want.Params) || got.Trailing != tt.want.Trailing {
+				t.Errorf("Parse() = %s %q %q, want %s %q %q",
+					got.Command, got.Params, got.Trailing, tt.want.Command, tt.want.Params, tt.want.Trailing)
+			}
+		})
+	}
+}
+
+func TestParseMissingCommand(t *testing.T) {
+	for _, line := range []string{"", "@id=1", ":tmi.twitch.tv"} {
+		if _, err := Parse(line); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", line)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package chat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"argus/bus"
+)
+
+// roomModes lists the ROOMSTATE tags that describe chat modes, with the value each has
+// when the mode is off.
+var roomModes = []struct {
+	tag string
+	off string
+}{
+	{"emote-only", "0"},
+	{"followers-only", "-1"},
+	{"r9k", "0"},
+	{"slow", "0"},
+	{"subs-only", "0"},
+}
+
+// handleUserNotice publishes subs, resubs, raids, announcements and other notices.
+func (c *Client) handleUserNotice(m Message) {
+	c.bus.Publish(bus.UserNotice{
+		Channel:       m.Param(0),
+		Type:          m.Tags["msg-id"],
+		User:          displayName(m),
+		SystemMessage: m.Tags["system-msg"],
+		Text:          m.Trailing,
+		Color:         m.Tags["msg-param-color"],
+	})
+}
+
+// handleClearChat publishes a ban, timeout or chat clear.
+func (c *Client) handleClearChat(m Message) {
+	event := bus.ClearChat{Channel: m.Param(0), User: m.Trailing}
+	if seconds, err := strconv.Atoi(m.Tags["ban-duration"]); err == nil {
+		event.Duration = time.Duration(seconds) * time.Second
+	}
+	c.bus.Publish(event)
+}
+
+// handleClearMsg publishes a deleted message.
+func (c *Client) handleClearMsg(m Message) {
+	c.bus.Publish(bus.ClearMessage{
+		Channel:   m.Param(0),
+		User:      m.Tags["login"],
+		MessageID: m.Tags["target-msg-id"],
+		Text:      m.Trailing,
+	})
+}
+
+// handleRoomState publishes the chat modes that changed since the last ROOMSTATE for the
+// channel. The first one after joining lists every mode, so only those that are on are
+// reported.
+func (c *Client) handleRoomState(m Message) {
+	channel := m.Param(0)
+	previous := c.roomState[channel]
+	if previous == nil {
+		previous = make(map[string]string)
+		c.roomState[channel] = previous
+	}
+
+	var changes []string
+	for _, mode := range roomModes {
+		value, ok := m.Tags[mode.tag]
+		if !ok {
+			continue
+		}
+		old, known := previous[mode.tag]
+		if !known {
+			old = mode.off
+		}
+		previous[mode.tag] = value
+		if value != old {
+			changes = append(changes, describeRoomMode(mode.tag, value, mode.off))
+		}
+	}
+
+	if len(changes) > 0 {
+		c.bus.Publish(bus.RoomState{Channel: channel, Changes: changes})
+	}
+}
+
+// describeRoomMode says in words what a ROOMSTATE tag value means.
+func describeRoomMode(tag, value, off string) string {
+	name := map[string]string{
+		"emote-only":     "emote-only mode",
+		"followers-only": "followers-only mode",
+		"r9k":            "unique chat mode",
+		"slow":           "slow mode",
+		"subs-only":      "subscribers-only mode",
+	}[tag]
+
+	switch {
+	case value == off:
+		return name + " off"
+	case tag == "slow":
+		return fmt.Sprintf("%s on (%ss)", name, value)
+	case tag == "followers-only" && value != "0":
+		return fmt.Sprintf("%s on (%s minutes)", name, value)
+	default:
+		return name + " on"
+	}
+}
+
+// handleNotice publishes a server notice. It returns an error when the notice means we
+// can't log in, so the session is dropped and retried with backoff.
+func (c *Client) handleNotice(m Message) error {
+	notice := bus.Notice{Channel: m.Param(0), ID: m.Tags["msg-id"], Text: m.Trailing}
+	if notice.Channel == "*" {
+		notice.Channel = ""
+	}
+	c.bus.Publish(notice)
+
+	if strings.Contains(notice.Text, "Login authentication failed") || strings.Contains(notice.Text, "Improperly formatted auth") {
+		return fmt.Errorf("authentication failed: %s", notice.Text)
+	}
+	return nil
+}
+
+// displayName returns the sender's display name, falling back to their login.
+func displayName(m Message) string {
+	if name := m.Tags["display-name"]; name != "" {
+		return name
+	}
+	if login := m.Tags["login"]; login != "" {
+		return login
+	}
+	return m.Prefix.Nick
+}
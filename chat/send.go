@@ -0,0 +1,262 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"argus/bus"
+	"argus/config"
+)
+
+// Twitch counts the messages an account sends over a sliding window, with a higher limit
+// in channels where it is a moderator or the broadcaster, and for verified bots.
+const (
+	rateWindow    = 30 * time.Second
+	normalLimit   = 20
+	modLimit      = 100
+	verifiedLimit = 7500
+)
+
+// maxMessageLength is the longest chat message Twitch accepts, in characters.
+const maxMessageLength = 500
+
+// maxTrackedMessages is how many recent message IDs are remembered for Reply.
+const maxTrackedMessages = 1000
+
+// maxQueuedReplies is how many ChatReply events may wait for the rate limit at once.
+const maxQueuedReplies = 1000
+
+// ErrNotConnected is returned when a message is sent while chat is not connected.
+var ErrNotConnected = errors.New("chat is not connected")
+
+// errClosed is returned when the client is closed while a message waits for the rate limit.
+var errClosed = errors.New("chat client closed")
+
+// rateLimiter limits the messages sent per rateWindow. Twitch counts them per account, so
+// one limiter is shared by every channel.
+type rateLimiter struct {
+	mu   sync.Mutex
+	sent []time.Time
+}
+
+// reserve waits until another message fits under limit and records it. It reports false
+// if done is closed first.
+func (r *rateLimiter) reserve(limit int, done <-chan struct{}) bool {
+	for {
+		r.mu.Lock()
+		now := time.Now()
+		expired := 0
+		for expired < len(r.sent) && now.Sub(r.sent[expired]) >= rateWindow {
+			expired++
+		}
+		r.sent = r.sent[expired:]
+
+		if len(r.sent) < limit {
+			r.sent = append(r.sent, now)
+			r.mu.Unlock()
+			return true
+		}
+		wait := rateWindow - now.Sub(r.sent[len(r.sent)-limit])
+		r.mu.Unlock()
+
+		select {
+		case <-done:
+			return false
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Say sends text to channel, waiting if the rate limit has been reached.
+func (c *Client) Say(channel, text string) error {
+	return c.send(channel, "", text)
+}
+
+// Reply sends text as a reply to the chat message with ID parentMsgID.
+func (c *Client) Reply(parentMsgID, text string) error {
+	// The ID goes into a tag unescaped, so anything but a message UUID could inject tags
+	// or commands.
+	if !validMessageID(parentMsgID) {
+		return fmt.Errorf("invalid message ID %q", parentMsgID)
+	}
+	c.mu.Lock()
+	channel, ok := c.messageChannels[parentMsgID]
+	c.mu.Unlock()
+	if !ok {
+		channel = c.cfg.Channel
+	}
+	return c.send(channel, parentMsgID, text)
+}
+
+// validMessageID reports whether id looks like a Twitch message ID, which is a UUID.
+func validMessageID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Client) send(channel, parentMsgID, text string) error {
+	channel = config.ChannelName(channel)
+	// A line break would end the IRC command and let the rest be sent as another one.
+	text = strings.TrimSpace(strings.NewReplacer("\r", " ", "\n", " ").Replace(text))
+	if text == "" {
+		return errors.New("message is empty")
+	}
+	if utf8.RuneCountInString(text) > maxMessageLength {
+		return fmt.Errorf("message is longer than %d characters", maxMessageLength)
+	}
+	if c.State() != StateConnected {
+		return ErrNotConnected
+	}
+
+	if !c.limiter.reserve(c.rateLimit(channel), c.done) {
+		return errClosed
+	}
+
+	line := fmt.Sprintf("PRIVMSG %s :%s", channel, text)
+	if parentMsgID != "" {
+		line = fmt.Sprintf("@reply-parent-msg-id=%s %s", parentMsgID, line)
+	}
+
+	c.mu.Lock()
+	conn := c.conn
+	connected := c.state == StateConnected
+	userState := maps.Clone(c.userState[channel])
+	c.mu.Unlock()
+	if !connected || conn == nil {
+		return ErrNotConnected
+	}
+	if _, err := fmt.Fprintf(conn, "%s\r\n", line); err != nil {
+		return err
+	}
+
+	// Twitch doesn't echo our own messages back, so show them like everyone else's.
+	msg := bus.ChatMessage{
+		Channel:     channel,
+		UserLogin:   strings.ToLower(c.cfg.Nick),
+		DisplayName: c.cfg.Nick,
+		Text:        text,
+		Tags:        userState,
+		Time:        time.Now(),
+		Self:        true,
+	}
+	if userState != nil {
+		msg.Color = userState["color"]
+		msg.Badges = userState["badges"]
+		if name := userState["display-name"]; name != "" {
+			msg.DisplayName = name
+		}
+	}
+	c.bus.Publish(msg)
+	return nil
+}
+
+// rateLimit returns how many messages may be sent to channel per rateWindow.
+func (c *Client) rateLimit(channel string) int {
+	if c.cfg.ChatVerified {
+		return verifiedLimit
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	badges := c.userState[channel]["badges"]
+	if c.userState[channel]["mod"] == "1" || strings.Contains(badges, "broadcaster/") || strings.Contains(badges, "moderator/") {
+		return modLimit
+	}
+	return normalLimit
+}
+
+// handleUserState remembers our own badges and color in a channel. Twitch sends it after
+// joining and after every message we send.
+func (c *Client) handleUserState(m Message) {
+	c.mu.Lock()
+	c.userState[m.Param(0)] = m.Tags
+	c.mu.Unlock()
+}
+
+// trackMessage remembers which channel a message was sent in, so Reply can find it.
+func (c *Client) trackMessage(id, channel string) {
+	if id == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messageChannels[id] = channel
+	c.messageOrder = append(c.messageOrder, id)
+	if len(c.messageOrder) > maxTrackedMessages {
+		delete(c.messageChannels, c.messageOrder[0])
+		c.messageOrder = c.messageOrder[1:]
+	}
+}
+
+// sendReplies sends every ChatReply published on the bus until the client is closed.
+// Sending can wait on the rate limit for up to rateWindow, and the bus drops events for
+// a subscriber whose buffer is full, so replies are taken off the bus right away and
+// queued for sendQueuedReplies.
+func (c *Client) sendReplies() {
+	events, unsubscribe := c.bus.Subscribe(64)
+	go func() {
+		<-c.done
+		unsubscribe()
+	}()
+
+	replies := make(chan bus.ChatReply)
+	defer close(replies)
+	go c.sendQueuedReplies(replies)
+
+	var queue []bus.ChatReply
+	for {
+		// Sending on a nil channel blocks, so the case is disabled while the queue is empty.
+		var next chan<- bus.ChatReply
+		var head bus.ChatReply
+		if len(queue) > 0 {
+			next, head = replies, queue[0]
+		}
+
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			reply, ok := event.(bus.ChatReply)
+			if !ok {
+				continue
+			}
+			if len(queue) >= maxQueuedReplies {
+				log.Printf("Dropping chat reply to %s: %d replies are already waiting", reply.Channel, len(queue))
+				continue
+			}
+			queue = append(queue, reply)
+		case next <- head:
+			queue = queue[1:]
+		}
+	}
+}
+
+// sendQueuedReplies sends replies one at a time, in the order they were published.
+func (c *Client) sendQueuedReplies(replies <-chan bus.ChatReply) {
+	for reply := range replies {
+		var err error
+		if reply.ParentID != "" {
+			err = c.Reply(reply.ParentID, reply.Text)
+		} else {
+			err = c.Say(reply.Channel, reply.Text)
+		}
+		if err != nil {
+			log.Printf("Error sending chat reply: %v", err)
+		}
+	}
+}
@@ -8,4 +8,12 @@ const (
 	ColorWhite        = "\033[97m"
 	ColorPurple       = "\033[35m"
 	ColorCyan         = "\033[36m"
+	ColorGreen        = "\033[32m"
+	ColorYellow       = "\033[33m"
+	ColorBlue         = "\033[34m"
+	ColorOrange       = "\033[38;5;208m"
+	ColorPink         = "\033[38;5;213m"
+	ColorGray         = "\033[90m"
+	ColorTeal         = "\033[38;5;36m"
+	ColorLime         = "\033[38;5;154m"
 )
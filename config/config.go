@@ -1,10 +1,14 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -17,8 +21,58 @@ type Config struct {
 	AppAccessToken string
 	Channel        string
 	ChannelID      string
-	ShowLogs       bool
-	Port           string
+	// Channels lists every channel to join, starting with Channel. Extra channels come from TWITCH_CHANNELS.
+	Channels []Channel
+	// ChannelEventTypes lists the EventSub subscription types created for each extra channel.
+	ChannelEventTypes []string
+	// UserID is the account behind OAuthToken. It defaults to ChannelID.
+	UserID string
+	// ChatVerified raises the chat rate limit to that of a verified bot account.
+	ChatVerified bool
+	ShowLogs     bool
+	Port         string
+	// EventTypes lists the EventSub subscription types to create, optionally pinned as "type@version".
+	EventTypes []string
+	// DisabledEvents lists EventSub subscription types that should not be subscribed to.
+	DisabledEvents []string
+	// NowPlayingProvider picks the now playing source by name. Empty picks the first available one.
+	NowPlayingProvider string
+	// PlayerPriority lists Linux player names in the order they are preferred. Wildcards are allowed.
+	PlayerPriority []string
+	// PlayerExclude lists Linux player names that are never used. Wildcards are allowed.
+	PlayerExclude []string
+	// PlayerAnyFallback falls back to any other playing player when none in PlayerPriority is playing.
+	PlayerAnyFallback bool
+	// MPDHost, MPDPort and MPDPassword say where the mpd provider finds the Music Player Daemon.
+	MPDHost     string
+	MPDPort     string
+	MPDPassword string
+	// ArtSize is the largest width or height album art is served at.
+	ArtSize int
+	// ArtPlaceholder is an image served when the current track has no album art.
+	ArtPlaceholder string
+	// HistoryPath is the JSON Lines file the song history is kept in.
+	HistoryPath string
+	// CommandsPath is the JSON file custom chat commands are loaded from.
+	CommandsPath string
+	// ChatOverlayTTL is how long a message stays on the chat overlay. Zero keeps messages forever.
+	ChatOverlayTTL time.Duration
+	// Alerts overrides how long and with which text each alert type is shown, keyed by type.
+	Alerts map[string]AlertSettings
+}
+
+// Channel is a Twitch channel Argus joins.
+type Channel struct {
+	// Name is the channel name in lower case, with the # prefix.
+	Name string
+	// ID is the numeric broadcaster ID. When empty it is looked up by name.
+	ID string
+}
+
+// AlertSettings overrides the defaults for one type of overlay alert.
+type AlertSettings struct {
+	Duration time.Duration
+	Template string
 }
 
 // Load reads configuration from environment (with optional .env) and validates required fields.
@@ -51,12 +105,88 @@ func Load() Config {
 		OAuthToken:     os.Getenv("TWITCH_TOKEN"),
 		Channel:        os.Getenv("TWITCH_CHANNEL"),
 		ChannelID:      os.Getenv("TWITCH_CHANNEL_ID"),
+		UserID:         os.Getenv("TWITCH_USER_ID"),
 		ClientID:       os.Getenv("TWITCH_CLIENT_ID"),
 		AppAccessToken: os.Getenv("TWITCH_APP_ACCESS_TOKEN"),
+		ChatVerified:   os.Getenv("CHAT_VERIFIED") == "true",
 		ShowLogs:       os.Getenv("SHOW_LOGS") == "true",
 		Port:           os.Getenv("PORT"),
+		EventTypes:     splitList(os.Getenv("EVENTSUB_TYPES")),
+		DisabledEvents: splitList(os.Getenv("EVENTSUB_DISABLED")),
+
+		NowPlayingProvider: os.Getenv("NOW_PLAYING_PROVIDER"),
+		PlayerPriority:     splitList(os.Getenv("PLAYER_PRIORITY")),
+		PlayerExclude:      splitList(os.Getenv("PLAYER_EXCLUDE")),
+		PlayerAnyFallback:  os.Getenv("PLAYER_ANY_FALLBACK") != "false",
+		MPDHost:            os.Getenv("MPD_HOST"),
+		MPDPort:            os.Getenv("MPD_PORT"),
+		MPDPassword:        os.Getenv("MPD_PASSWORD"),
+		ArtPlaceholder:     os.Getenv("ART_PLACEHOLDER"),
+		HistoryPath:        os.Getenv("HISTORY_FILE"),
+		CommandsPath:       os.Getenv("COMMANDS_FILE"),
+	}
+
+	if len(cfg.PlayerPriority) == 0 {
+		cfg.PlayerPriority = []string{"spotify", "vlc"}
+	}
+	if cfg.MPDHost == "" {
+		cfg.MPDHost = "localhost"
+	}
+	if cfg.MPDPort == "" {
+		cfg.MPDPort = "6600"
+	}
+
+	if cfg.HistoryPath == "" {
+		cfg.HistoryPath = filepath.Join(homeDir, ".config", "argus", "history.jsonl")
+	}
+
+	if cfg.CommandsPath == "" {
+		cfg.CommandsPath = filepath.Join(homeDir, ".config", "argus", "commands.json")
+	}
+
+	if cfg.UserID == "" {
+		cfg.UserID = cfg.ChannelID
+	}
+
+	if cfg.Channel != "" {
+		cfg.Channel = ChannelName(cfg.Channel)
+		cfg.Channels = []Channel{{Name: cfg.Channel, ID: cfg.ChannelID}}
+	}
+	for _, item := range splitList(os.Getenv("TWITCH_CHANNELS")) {
+		name, id, _ := strings.Cut(item, ":")
+		channel := Channel{Name: ChannelName(name), ID: strings.TrimSpace(id)}
+		if !slices.ContainsFunc(cfg.Channels, func(c Channel) bool { return c.Name == channel.Name }) {
+			cfg.Channels = append(cfg.Channels, channel)
+		}
+	}
+
+	cfg.ChannelEventTypes = splitList(os.Getenv("EVENTSUB_CHANNEL_TYPES"))
+	if len(cfg.ChannelEventTypes) == 0 {
+		cfg.ChannelEventTypes = []string{"channel.raid", "channel.follow"}
 	}
 
+	cfg.ArtSize = 300
+	if size := os.Getenv("ART_SIZE"); size != "" {
+		cfg.ArtSize, err = strconv.Atoi(size)
+		if err != nil {
+			log.Fatalf("Invalid ART_SIZE: %v", err)
+		}
+	}
+
+	cfg.ChatOverlayTTL = 60 * time.Second
+	if ttl := os.Getenv("CHAT_OVERLAY_TTL"); ttl != "" {
+		cfg.ChatOverlayTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			log.Fatalf("Invalid CHAT_OVERLAY_TTL: %v", err)
+		}
+	}
+
+	alerts, err := loadAlerts()
+	if err != nil {
+		log.Fatalf("Invalid alert configuration: %v", err)
+	}
+	cfg.Alerts = alerts
+
 	var missingVars []string
 	if cfg.Nick == "" {
 		missingVars = append(missingVars, "TWITCH_NICK")
@@ -86,3 +216,47 @@ func Load() Config {
 
 	return cfg
 }
+
+// ChannelName normalizes a channel name to lower case with the # prefix, as IRC uses it.
+func ChannelName(name string) string {
+	return "#" + strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+}
+
+// splitList parses a comma-separated setting, dropping empty entries and surrounding whitespace.
+func splitList(value string) []string {
+	var items []string
+	for item := range strings.SplitSeq(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// loadAlerts collects ALERT_<TYPE>_DURATION and ALERT_<TYPE>_TEMPLATE settings,
+// for example ALERT_CHEER_DURATION=8s or ALERT_GIFT_SUB_TEMPLATE={{.User}} gifted {{.Total}} subs.
+func loadAlerts() (map[string]AlertSettings, error) {
+	alerts := make(map[string]AlertSettings)
+	for _, env := range os.Environ() {
+		key, value, _ := strings.Cut(env, "=")
+		name, ok := strings.CutPrefix(key, "ALERT_")
+		if !ok || value == "" {
+			continue
+		}
+
+		if kind, ok := strings.CutSuffix(name, "_DURATION"); ok {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			settings := alerts[strings.ToLower(kind)]
+			settings.Duration = duration
+			alerts[strings.ToLower(kind)] = settings
+		} else if kind, ok := strings.CutSuffix(name, "_TEMPLATE"); ok {
+			settings := alerts[strings.ToLower(kind)]
+			settings.Template = value
+			alerts[strings.ToLower(kind)] = settings
+		}
+	}
+	return alerts, nil
+}
@@ -23,16 +23,3 @@ func (d *Dependency) Check() (bool, string) {
 	}
 	return true, ""
 }
-
-// GetNowPlayingDependency returns the appropriate dependency based on the current OS.
-func GetNowPlayingDependency() *Dependency {
-	switch runtime.GOOS {
-	case "linux":
-		return &Dependency{Command: "playerctl", OS: "linux"}
-	case "darwin": // macOS
-		return &Dependency{Command: "nowplaying-cli", OS: "darwin"}
-	default:
-		return nil
-	}
-}
-
@@ -1,15 +1,22 @@
 package events
 
 import (
-	"argus/colors"
+	"argus/backoff"
+	"argus/bus"
 	"argus/config"
+	"argus/health"
+	"argus/helix"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -20,182 +27,501 @@ const (
 	API_URL      = "https://api.twitch.tv/helix/eventsub/subscriptions"
 )
 
-func Run(cfg config.Config) {
-	u := url.URL{Scheme: "wss", Host: "eventsub.wss.twitch.tv", Path: "/ws"}
-	if cfg.ShowLogs {
-		log.Printf("Connecting to EventSub at %s", u.String())
+// healthName is the key EventSub reports its status under.
+const healthName = "eventsub"
+
+const (
+	// welcomeTimeout bounds how long a fresh socket may stay silent before its welcome arrives.
+	welcomeTimeout = 10 * time.Second
+	// keepaliveGrace is added to Twitch's keepalive window to absorb network jitter.
+	keepaliveGrace = 2 * time.Second
+)
+
+// httpClient bounds every subscription request so a stalled Helix call can't hang a session.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// errKeepaliveTimeout is returned when the socket stays silent past the keepalive window.
+var errKeepaliveTimeout = errors.New("no message received within the keepalive window")
+
+// Client keeps an EventSub websocket session alive, following Twitch's reconnect
+// requests and re-dialing from scratch when the connection is lost.
+type Client struct {
+	cfg config.Config
+	bus *bus.Bus
+
+	// URL is the EventSub endpoint dialed for a brand-new session.
+	URL string
+	// Types are the subscriptions created for the first channel in every new session.
+	Types []EventType
+	// ChannelTypes are the subscriptions created for every other channel.
+	ChannelTypes []EventType
+
+	// channels are the configured channels, with broadcaster IDs filled in once looked up.
+	// mu guards it, since subscriptions are created off the frame loop.
+	mu       sync.Mutex
+	channels []config.Channel
+	helix    *helix.Client
+
+	backoff *backoff.Backoff
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewClient creates an EventSub client for the configured channels. It fails if the
+// configured subscription types don't match the registry.
+func NewClient(cfg config.Config, b *bus.Bus) (*Client, error) {
+	types, err := ResolveEventTypes(cfg)
+	if err != nil {
+		return nil, err
+	}
+	channelTypes, err := ResolveChannelEventTypes(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	return &Client{
+		cfg:          cfg,
+		bus:          b,
+		URL:          EVENTSUB_URL,
+		Types:        types,
+		ChannelTypes: channelTypes,
+		channels:     slices.Clone(cfg.Channels),
+		helix:        helix.NewClient(cfg.ClientID, cfg.AppAccessToken),
+		backoff:      backoff.New(time.Second, 2*time.Minute),
+		done:         make(chan struct{}),
+	}, nil
+}
+
+// Run starts an EventSub client that lives until the process exits.
+func Run(cfg config.Config, b *bus.Bus) {
+	client, err := NewClient(cfg, b)
 	if err != nil {
-		log.Fatal("WebSocket connection error:", err)
+		log.Fatalf("Invalid EventSub configuration: %v", err)
 	}
-	defer conn.Close()
+	client.Run()
+}
 
-	done := make(chan struct{})
+// Run keeps an EventSub session open until Close is called.
+func (c *Client) Run() {
+	for {
+		err := c.session()
 
-	go func() {
-		defer close(done)
-		for {
-			_, message, err := conn.ReadMessage()
-			if err != nil {
-				if cfg.ShowLogs {
-					log.Println("Read error:", err)
-				}
-				return
+		select {
+		case <-c.done:
+			if c.cfg.ShowLogs {
+				log.Println("EventSub connection closed.")
 			}
-			var msg map[string]any
-			if err := json.Unmarshal(message, &msg); err != nil {
-				if cfg.ShowLogs {
-					log.Println("JSON unmarshal error:", err)
+			c.setState(health.StateDisconnected, nil)
+			return
+		default:
+		}
+
+		delay := c.backoff.Next()
+		log.Printf("EventSub connection lost: %v. Reconnecting in %s", err, delay.Round(time.Millisecond))
+		c.setState(health.StateReconnecting, err)
+
+		select {
+		case <-c.done:
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Close stops the client and tears down the open session.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+// setState reports a connection state change to the health endpoint and the bus.
+func (c *Client) setState(state string, err error) {
+	health.Set(healthName, state, err)
+
+	event := bus.ConnectionState{Service: healthName, State: state}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	c.bus.Publish(event)
+}
+
+// frame is a single websocket read, tagged with the connection it came from so the
+// session loop can tell the current socket apart from one it is migrating to.
+type frame struct {
+	conn *websocket.Conn
+	data []byte
+	err  error
+}
+
+// session runs one EventSub session, including any reconnect migrations Twitch asks
+// for along the way. It returns once the session is lost and must be started over,
+// which also means subscriptions have to be created again.
+func (c *Client) session() error {
+	frames := make(chan frame)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	if c.cfg.ShowLogs {
+		log.Printf("Connecting to EventSub at %s", c.URL)
+	}
+	c.setState(health.StateConnecting, nil)
+	active, err := dial(c.URL, frames, stop)
+	if err != nil {
+		return err
+	}
+	// pending is the socket we're migrating to after a session_reconnect. Twitch wants
+	// the old socket kept open until the new one has sent its welcome.
+	var pending *websocket.Conn
+	defer func() {
+		if active != nil {
+			active.Close()
+		}
+		if pending != nil {
+			pending.Close()
+		}
+	}()
+
+	seen := newRecentIDs(100)
+
+	// The watchdog fires when the active socket goes quiet for longer than the keepalive
+	// window, which is the only way to notice a half-open connection.
+	keepalive := welcomeTimeout
+	watchdog := time.NewTimer(keepalive)
+	defer watchdog.Stop()
+
+	for {
+		var f frame
+		select {
+		case <-c.done:
+			return nil
+		case <-watchdog.C:
+			log.Printf("EventSub keepalive timeout: nothing received for %s. Starting a new session", keepalive)
+			return errKeepaliveTimeout
+		case f = <-frames:
+		}
+
+		if f.conn != active && f.conn != pending {
+			// Leftover read from a socket we already dropped.
+			continue
+		}
+		if f.err == nil && f.conn == active {
+			watchdog.Reset(keepalive)
+			health.Touch(healthName)
+		}
+
+		if f.err != nil {
+			if f.conn == pending {
+				log.Printf("EventSub reconnect failed: %v", f.err)
+				pending.Close()
+				pending = nil
+				if active == nil {
+					return f.err
 				}
 				continue
 			}
+			if pending != nil {
+				// Twitch closed the old socket first; the new one will take over on welcome.
+				active.Close()
+				active = nil
+				continue
+			}
+			return f.err
+		}
+
+		var msg Message
+		if err := json.Unmarshal(f.data, &msg); err != nil {
+			if c.cfg.ShowLogs {
+				log.Println("JSON unmarshal error:", err)
+			}
+			continue
+		}
 
-			metadata, ok := msg["metadata"].(map[string]any)
-			if !ok {
-				if cfg.ShowLogs {
-					log.Println("metadata not found in message")
+		switch msg.Metadata.MessageType {
+		case "session_welcome":
+			var payload SessionPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				return fmt.Errorf("decoding session welcome: %w", err)
+			}
+			c.backoff.Reset()
+			c.setState(health.StateConnected, nil)
+			health.Touch(healthName)
+			if payload.Session.KeepaliveTimeoutSeconds > 0 {
+				keepalive = time.Duration(payload.Session.KeepaliveTimeoutSeconds)*time.Second + keepaliveGrace
+			}
+			watchdog.Reset(keepalive)
+
+			if f.conn == pending {
+				// Subscriptions carry over to the new socket, so there is nothing to re-create.
+				if active != nil {
+					active.Close()
+				}
+				active, pending = pending, nil
+				if c.cfg.ShowLogs {
+					log.Println("Migrated EventSub session to new connection. Session ID:", payload.Session.ID)
 				}
 				continue
 			}
 
-			messageType, ok := metadata["message_type"].(string)
-			if !ok {
-				if cfg.ShowLogs {
-					log.Println("message_type not found in metadata")
+			if c.cfg.ShowLogs {
+				log.Println("Received session welcome. Session ID:", payload.Session.ID)
+			}
+			// Subscribing takes several Helix round trips; keep reading frames meanwhile so
+			// keepalives and notifications aren't held up.
+			go c.subscribe(payload.Session.ID)
+		case "session_keepalive":
+			if c.cfg.ShowLogs {
+				log.Println("Received keepalive message.")
+			}
+		case "notification":
+			if !seen.add(msg.Metadata.MessageID) {
+				// Twitch may deliver the same notification on both sockets during a migration.
+				continue
+			}
+			var payload NotificationPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				if c.cfg.ShowLogs {
+					log.Println("Error decoding notification payload:", err)
 				}
 				continue
 			}
+			c.handleNotification(payload)
+		case "revocation":
+			var payload RevocationPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err == nil {
+				log.Printf("EventSub subscription %s was revoked: %s", payload.Subscription.Type, payload.Subscription.Status)
+			}
+		case "session_reconnect":
+			var payload SessionPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil || payload.Session.ReconnectURL == "" {
+				return errors.New("session_reconnect without a reconnect_url")
+			}
+			if pending != nil {
+				pending.Close()
+				pending = nil
+			}
+			if c.cfg.ShowLogs {
+				log.Printf("Received reconnect message. Migrating to %s", payload.Session.ReconnectURL)
+			}
+			conn, err := dial(payload.Session.ReconnectURL, frames, stop)
+			if err != nil {
+				// Keep using the old socket; if Twitch closes it we'll start over from scratch.
+				log.Printf("EventSub reconnect failed: %v", err)
+				continue
+			}
+			pending = conn
+		default:
+			if c.cfg.ShowLogs {
+				log.Printf("Received unhandled message type: %s", msg.Metadata.MessageType)
+			}
+		}
+	}
+}
 
-			switch messageType {
-			case "session_welcome":
-				sessionID := msg["payload"].(map[string]any)["session"].(map[string]any)["id"].(string)
-				if cfg.ShowLogs {
-					log.Println("Received session welcome. Session ID:", sessionID)
-				}
-				subscribeToEvents(sessionID, cfg)
-			case "session_keepalive":
-				if cfg.ShowLogs {
-					log.Println("Received keepalive message.")
-				}
-			case "notification":
-				handleEventSubNotification(msg, cfg)
-			case "revocation":
-				if cfg.ShowLogs {
-					log.Println("Received revocation. Session revoked.")
-				}
+// dial opens a websocket and forwards everything read from it to frames until stop is closed.
+func dial(rawURL string, frames chan<- frame, stop <-chan struct{}) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("websocket connection error: %w", err)
+	}
+
+	go func() {
+		for {
+			_, data, err := conn.ReadMessage()
+			select {
+			case frames <- frame{conn: conn, data: data, err: err}:
+			case <-stop:
+				conn.Close()
 				return
-			case "session_reconnect":
-				if cfg.ShowLogs {
-					log.Println("Received reconnect message. Disconnecting and reconnecting...")
-				}
+			}
+			if err != nil {
 				return
-			default:
-				if cfg.ShowLogs {
-					log.Printf("Received unhandled message type: %s", messageType)
-				}
 			}
 		}
 	}()
 
-	<-done
-	if cfg.ShowLogs {
-		log.Println("EventSub connection closed.")
+	return conn, nil
+}
+
+// recentIDs remembers the last few message IDs so duplicate deliveries can be dropped.
+type recentIDs struct {
+	ids   map[string]struct{}
+	order []string
+	limit int
+}
+
+func newRecentIDs(limit int) *recentIDs {
+	return &recentIDs{ids: make(map[string]struct{}, limit), limit: limit}
+}
+
+// add records id and reports whether it was new.
+func (r *recentIDs) add(id string) bool {
+	if id == "" {
+		return true
+	}
+	if _, ok := r.ids[id]; ok {
+		return false
 	}
+	if len(r.order) == r.limit {
+		delete(r.ids, r.order[0])
+		r.order = r.order[1:]
+	}
+	r.ids[id] = struct{}{}
+	r.order = append(r.order, id)
+	return true
 }
 
-func subscribeToEvents(sessionID string, cfg config.Config) {
-	subscriptionTypes := []string{
-		"channel.subscribe",
-		"channel.cheer",
-		"channel.channel_points_custom_reward_redemption.add",
+// subscribe creates the subscriptions for a new session: Types for the first channel and
+// ChannelTypes for every other one.
+func (c *Client) subscribe(sessionID string) {
+	c.resolveChannelIDs()
+	c.mu.Lock()
+	channels := slices.Clone(c.channels)
+	c.mu.Unlock()
+	for i, channel := range channels {
+		if channel.ID == "" {
+			continue
+		}
+		types := c.Types
+		if i > 0 {
+			types = c.ChannelTypes
+		}
+		subscribeToEvents(sessionID, types, channel, c.cfg)
 	}
+}
 
-	for _, eventType := range subscriptionTypes {
-		data := map[string]any{
-			"type":      eventType,
-			"version":   "1",
-			"condition": map[string]string{"broadcaster_user_id": cfg.ChannelID},
-			"transport": map[string]string{"method": "websocket", "session_id": sessionID},
+// resolveChannelIDs looks up the broadcaster IDs that weren't configured. Channels that
+// can't be found are skipped until the next session tries again.
+func (c *Client) resolveChannelIDs() {
+	var logins []string
+	c.mu.Lock()
+	for _, channel := range c.channels {
+		if channel.ID == "" {
+			logins = append(logins, strings.TrimPrefix(channel.Name, "#"))
 		}
+	}
+	c.mu.Unlock()
+	if len(logins) == 0 {
+		return
+	}
 
-		jsonData, err := json.Marshal(data)
-		if err != nil {
-			if cfg.ShowLogs {
-				log.Printf("Error marshalling JSON for %s: %v", eventType, err)
-			}
+	users, err := c.helix.UsersByLogin(logins...)
+	if err != nil {
+		log.Printf("Error looking up channel IDs: %v", err)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, channel := range c.channels {
+		if channel.ID != "" {
 			continue
 		}
-
-		client := &http.Client{}
-		req, err := http.NewRequest("POST", API_URL, bytes.NewBuffer(jsonData))
-		if err != nil {
-			if cfg.ShowLogs {
-				log.Printf("Error creating request for %s: %v", eventType, err)
+		for _, user := range users {
+			if "#"+user.Login == channel.Name {
+				c.channels[i].ID = user.ID
 			}
-			continue
 		}
+		if c.channels[i].ID == "" {
+			log.Printf("Twitch channel %s not found, not subscribing to its events", channel.Name)
+		}
+	}
+}
 
-		req.Header.Add("Client-ID", cfg.ClientID)
-		req.Header.Add("Authorization", "Bearer "+cfg.OAuthToken)
-		req.Header.Add("Content-Type", "application/json")
+// ChannelID returns the broadcaster ID of a configured channel, or "" if it hasn't been
+// looked up yet.
+func (c *Client) ChannelID(channel string) string {
+	channel = config.ChannelName(channel)
 
-		resp, err := client.Do(req)
-		if err != nil {
-			if cfg.ShowLogs {
-				log.Printf("Error making request for %s: %v", eventType, err)
-			}
-			continue
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ch := range c.channels {
+		if ch.Name == channel {
+			return ch.ID
 		}
-		defer resp.Body.Close()
+	}
+	return ""
+}
 
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			if cfg.ShowLogs {
-				log.Printf("Error reading response body for %s: %v", eventType, err)
+// channelName returns the name of the channel a subscription condition is for.
+func (c *Client) channelName(condition map[string]string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, field := range []string{ConditionBroadcaster, ConditionToBroadcaster} {
+		for _, channel := range c.channels {
+			if id := condition[field]; id != "" && id == channel.ID {
+				return channel.Name
 			}
-			continue
 		}
+	}
+	return ""
+}
 
-		if resp.StatusCode == http.StatusAccepted || resp.StatusCode == http.StatusOK {
+func subscribeToEvents(sessionID string, types []EventType, channel config.Channel, cfg config.Config) {
+	for _, eventType := range types {
+		if err := createSubscription(sessionID, eventType, channel.ID, cfg); err != nil {
 			if cfg.ShowLogs {
-				log.Printf("Successfully subscribed to %s", eventType)
-			}
-		} else {
-			if cfg.ShowLogs {
-				log.Printf("Failed to subscribe to %s. Status: %s, Body: %s", eventType, resp.Status, string(bodyBytes))
+				log.Printf("Failed to subscribe to %s for %s: %v", eventType.Type, channel.Name, err)
 			}
+			continue
+		}
+		if cfg.ShowLogs {
+			log.Printf("Successfully subscribed to %s for %s", eventType.Type, channel.Name)
 		}
 	}
 }
 
-func handleEventSubNotification(msg map[string]any, cfg config.Config) {
-	payload, ok := msg["payload"].(map[string]any)
-	if !ok {
-		return
+// createSubscription asks Helix to deliver eventType for the channel of broadcasterID to
+// the given websocket session.
+func createSubscription(sessionID string, eventType EventType, broadcasterID string, cfg config.Config) error {
+	data := map[string]any{
+		"type":      eventType.Type,
+		"version":   eventType.Version,
+		"condition": eventType.condition(broadcasterID, cfg.UserID),
+		"transport": Transport{Method: "websocket", SessionID: sessionID},
 	}
-	event, ok := payload["event"].(map[string]any)
-	if !ok {
-		return
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshalling JSON: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", API_URL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Add("Client-ID", cfg.ClientID)
+	req.Header.Add("Authorization", "Bearer "+cfg.OAuthToken)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("making request: %w", err)
 	}
-	eventType, ok := payload["subscription"].(map[string]any)["type"].(string)
-	if !ok {
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status: %s, body: %s", resp.Status, string(bodyBytes))
+	}
+	return nil
+}
+
+// handleNotification decodes a notification and publishes it on the bus.
+func (c *Client) handleNotification(payload NotificationPayload) {
+	event, err := DecodeNotification(payload)
+	if err != nil {
+		// Twitch may add fields or types we don't know about yet; that must never take the app down.
+		if c.cfg.ShowLogs {
+			log.Printf("Skipping EventSub notification: %v", err)
+		}
 		return
 	}
 
-	switch eventType {
-	case "channel.subscribe":
-		username := event["user_name"].(string)
-		fmt.Printf("%s [ACTIVITY] New Subscriber: %s!%s\n", colors.ColorWhite, username, colors.ColorReset)
-	case "channel.cheer":
-		username := event["user_name"].(string)
-		bitsAmount := event["bits"].(float64)
-		fmt.Printf("%s [ACTIVITY] %s cheered %d bits!%s\n", colors.ColorPurple, username, int(bitsAmount), colors.ColorReset)
-	case "channel.channel_points_custom_reward_redemption.add":
-		username := event["user_name"].(string)
-		rewardTitle := event["reward"].(map[string]any)["title"].(string)
-		rewardCost := event["reward"].(map[string]any)["cost"].(float64)
-		fmt.Printf("%s [ACTIVITY] %s redeemed %d channel points for: %s%s\n", colors.ColorCyan, username, int(rewardCost), rewardTitle, colors.ColorReset)
+	if p, ok := event.(publisher); ok {
+		c.bus.Publish(withChannel(p.busEvent(), c.channelName(payload.Subscription.Condition)))
 	}
 }
@@ -0,0 +1,283 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Message is the envelope shared by every message on the EventSub websocket.
+type Message struct {
+	Metadata Metadata        `json:"metadata"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// Metadata identifies a message and, for notifications, the subscription it belongs to.
+type Metadata struct {
+	MessageID           string    `json:"message_id"`
+	MessageType         string    `json:"message_type"`
+	MessageTimestamp    time.Time `json:"message_timestamp"`
+	SubscriptionType    string    `json:"subscription_type,omitempty"`
+	SubscriptionVersion string    `json:"subscription_version,omitempty"`
+}
+
+// Session describes the websocket session in welcome and reconnect messages.
+type Session struct {
+	ID                      string    `json:"id"`
+	Status                  string    `json:"status"`
+	ConnectedAt             time.Time `json:"connected_at"`
+	KeepaliveTimeoutSeconds int       `json:"keepalive_timeout_seconds"`
+	ReconnectURL            string    `json:"reconnect_url"`
+}
+
+// SessionPayload is the payload of session_welcome and session_reconnect messages.
+type SessionPayload struct {
+	Session Session `json:"session"`
+}
+
+// Subscription describes an EventSub subscription as Twitch reports it back.
+type Subscription struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	Version   string            `json:"version"`
+	Status    string            `json:"status"`
+	Cost      int               `json:"cost"`
+	Condition map[string]string `json:"condition"`
+	Transport Transport         `json:"transport"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Transport is where Twitch delivers notifications for a subscription.
+type Transport struct {
+	Method    string `json:"method"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// NotificationPayload is the payload of a notification message. Event is decoded
+// separately once the subscription type is known.
+type NotificationPayload struct {
+	Subscription Subscription    `json:"subscription"`
+	Event        json.RawMessage `json:"event"`
+}
+
+// RevocationPayload is the payload of a revocation message.
+type RevocationPayload struct {
+	Subscription Subscription `json:"subscription"`
+}
+
+// SubscribeEvent is sent for channel.subscribe.
+type SubscribeEvent struct {
+	UserID    string `json:"user_id"`
+	UserLogin string `json:"user_login"`
+	UserName  string `json:"user_name"`
+	Tier      string `json:"tier"`
+	IsGift    bool   `json:"is_gift"`
+}
+
+// CheerEvent is sent for channel.cheer.
+type CheerEvent struct {
+	IsAnonymous bool   `json:"is_anonymous"`
+	UserID      string `json:"user_id"`
+	UserLogin   string `json:"user_login"`
+	UserName    string `json:"user_name"`
+	Message     string `json:"message"`
+	Bits        int    `json:"bits"`
+}
+
+// RedemptionEvent is sent for channel.channel_points_custom_reward_redemption.add.
+type RedemptionEvent struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	UserLogin  string    `json:"user_login"`
+	UserName   string    `json:"user_name"`
+	UserInput  string    `json:"user_input"`
+	Status     string    `json:"status"`
+	Reward     Reward    `json:"reward"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+// Reward is the channel point reward that was redeemed.
+type Reward struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Cost   int    `json:"cost"`
+	Prompt string `json:"prompt"`
+}
+
+// FollowEvent is sent for channel.follow.
+type FollowEvent struct {
+	UserID     string    `json:"user_id"`
+	UserLogin  string    `json:"user_login"`
+	UserName   string    `json:"user_name"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// RaidEvent is sent for channel.raid.
+type RaidEvent struct {
+	FromBroadcasterUserID    string `json:"from_broadcaster_user_id"`
+	FromBroadcasterUserLogin string `json:"from_broadcaster_user_login"`
+	FromBroadcasterUserName  string `json:"from_broadcaster_user_name"`
+	Viewers                  int    `json:"viewers"`
+}
+
+// GiftSubEvent is sent for channel.subscription.gift.
+type GiftSubEvent struct {
+	UserID          string `json:"user_id"`
+	UserLogin       string `json:"user_login"`
+	UserName        string `json:"user_name"`
+	Total           int    `json:"total"`
+	Tier            string `json:"tier"`
+	CumulativeTotal *int   `json:"cumulative_total"`
+	IsAnonymous     bool   `json:"is_anonymous"`
+}
+
+// ResubEvent is sent for channel.subscription.message.
+type ResubEvent struct {
+	UserID           string       `json:"user_id"`
+	UserLogin        string       `json:"user_login"`
+	UserName         string       `json:"user_name"`
+	Tier             string       `json:"tier"`
+	Message          ResubMessage `json:"message"`
+	CumulativeMonths int          `json:"cumulative_months"`
+	StreakMonths     *int         `json:"streak_months"`
+	DurationMonths   int          `json:"duration_months"`
+}
+
+// ResubMessage is the text a viewer attached to a resub.
+type ResubMessage struct {
+	Text string `json:"text"`
+}
+
+// HypeTrain holds the fields shared by the hype train events.
+type HypeTrain struct {
+	ID             string    `json:"id"`
+	Level          int       `json:"level"`
+	Total          int       `json:"total"`
+	Progress       int       `json:"progress"`
+	Goal           int       `json:"goal"`
+	StartedAt      time.Time `json:"started_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	EndedAt        time.Time `json:"ended_at"`
+	CooldownEndsAt time.Time `json:"cooldown_ends_at"`
+}
+
+// HypeTrainBeginEvent is sent for channel.hype_train.begin.
+type HypeTrainBeginEvent struct{ HypeTrain }
+
+// HypeTrainProgressEvent is sent for channel.hype_train.progress.
+type HypeTrainProgressEvent struct{ HypeTrain }
+
+// HypeTrainEndEvent is sent for channel.hype_train.end.
+type HypeTrainEndEvent struct{ HypeTrain }
+
+// Poll holds the fields shared by the poll events.
+type Poll struct {
+	ID      string       `json:"id"`
+	Title   string       `json:"title"`
+	Choices []PollChoice `json:"choices"`
+	Status  string       `json:"status"`
+	EndsAt  time.Time    `json:"ends_at"`
+}
+
+// PollChoice is one answer in a poll.
+type PollChoice struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Votes int    `json:"votes"`
+}
+
+// PollBeginEvent is sent for channel.poll.begin.
+type PollBeginEvent struct{ Poll }
+
+// PollProgressEvent is sent for channel.poll.progress.
+type PollProgressEvent struct{ Poll }
+
+// PollEndEvent is sent for channel.poll.end.
+type PollEndEvent struct{ Poll }
+
+// Prediction holds the fields shared by the prediction events.
+type Prediction struct {
+	ID               string              `json:"id"`
+	Title            string              `json:"title"`
+	Outcomes         []PredictionOutcome `json:"outcomes"`
+	WinningOutcomeID string              `json:"winning_outcome_id"`
+	Status           string              `json:"status"`
+	LocksAt          time.Time           `json:"locks_at"`
+}
+
+// PredictionOutcome is one side of a prediction.
+type PredictionOutcome struct {
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	Color         string `json:"color"`
+	Users         int    `json:"users"`
+	ChannelPoints int    `json:"channel_points"`
+}
+
+// PredictionBeginEvent is sent for channel.prediction.begin.
+type PredictionBeginEvent struct{ Prediction }
+
+// PredictionProgressEvent is sent for channel.prediction.progress.
+type PredictionProgressEvent struct{ Prediction }
+
+// PredictionLockEvent is sent for channel.prediction.lock.
+type PredictionLockEvent struct{ Prediction }
+
+// PredictionEndEvent is sent for channel.prediction.end.
+type PredictionEndEvent struct{ Prediction }
+
+// AdBreakEvent is sent for channel.ad_break.begin.
+type AdBreakEvent struct {
+	DurationSeconds   int       `json:"duration_seconds"`
+	StartedAt         time.Time `json:"started_at"`
+	IsAutomatic       bool      `json:"is_automatic"`
+	RequesterUserName string    `json:"requester_user_name"`
+}
+
+// ShoutoutCreateEvent is sent for channel.shoutout.create.
+type ShoutoutCreateEvent struct {
+	ToBroadcasterUserID    string `json:"to_broadcaster_user_id"`
+	ToBroadcasterUserLogin string `json:"to_broadcaster_user_login"`
+	ToBroadcasterUserName  string `json:"to_broadcaster_user_name"`
+	ModeratorUserName      string `json:"moderator_user_name"`
+	ViewerCount            int    `json:"viewer_count"`
+}
+
+// ShoutoutReceiveEvent is sent for channel.shoutout.receive.
+type ShoutoutReceiveEvent struct {
+	FromBroadcasterUserID    string `json:"from_broadcaster_user_id"`
+	FromBroadcasterUserLogin string `json:"from_broadcaster_user_login"`
+	FromBroadcasterUserName  string `json:"from_broadcaster_user_name"`
+	ViewerCount              int    `json:"viewer_count"`
+}
+
+func decodeEvent[T any](raw json.RawMessage) (any, error) {
+	var event T
+	if err := json.Unmarshal(raw, &event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// unknownEventError is returned for subscription types we have no decoder for.
+type unknownEventError struct {
+	Type    string
+	Version string
+}
+
+func (e unknownEventError) Error() string {
+	return fmt.Sprintf("no decoder for %s version %s", e.Type, e.Version)
+}
+
+// DecodeNotification decodes a notification payload into its typed event struct.
+func DecodeNotification(payload NotificationPayload) (any, error) {
+	eventType, ok := LookupEventType(payload.Subscription.Type, payload.Subscription.Version)
+	if !ok {
+		return nil, unknownEventError{Type: payload.Subscription.Type, Version: payload.Subscription.Version}
+	}
+	event, err := eventType.decode(payload.Event)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s event: %w", payload.Subscription.Type, err)
+	}
+	return event, nil
+}
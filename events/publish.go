@@ -0,0 +1,168 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+
+	"argus/bus"
+)
+
+// publisher is implemented by every event that is shared with the rest of Argus.
+type publisher interface {
+	// busEvent converts the EventSub event into its bus representation.
+	busEvent() bus.Event
+}
+
+// withChannel sets the channel an activity event happened in.
+func withChannel(event bus.Event, channel string) bus.Event {
+	switch e := event.(type) {
+	case bus.Follow:
+		e.Channel = channel
+		return e
+	case bus.Raid:
+		e.Channel = channel
+		return e
+	case bus.Subscribe:
+		e.Channel = channel
+		return e
+	case bus.GiftSub:
+		e.Channel = channel
+		return e
+	case bus.Cheer:
+		e.Channel = channel
+		return e
+	case bus.Redemption:
+		e.Channel = channel
+		return e
+	case bus.Activity:
+		e.Channel = channel
+		return e
+	}
+	return event
+}
+
+func (e FollowEvent) busEvent() bus.Event {
+	return bus.Follow{User: e.UserName}
+}
+
+func (e RaidEvent) busEvent() bus.Event {
+	return bus.Raid{From: e.FromBroadcasterUserName, Viewers: e.Viewers}
+}
+
+func (e SubscribeEvent) busEvent() bus.Event {
+	return bus.Subscribe{User: e.UserName, Tier: tierName(e.Tier), Gift: e.IsGift}
+}
+
+func (e GiftSubEvent) busEvent() bus.Event {
+	username := e.UserName
+	if e.IsAnonymous {
+		username = "Anonymous"
+	}
+	return bus.GiftSub{User: username, Total: e.Total, Tier: tierName(e.Tier), Anonymous: e.IsAnonymous}
+}
+
+func (e ResubEvent) busEvent() bus.Event {
+	return bus.Subscribe{User: e.UserName, Tier: tierName(e.Tier), Months: e.CumulativeMonths, Message: e.Message.Text}
+}
+
+func (e CheerEvent) busEvent() bus.Event {
+	username := e.UserName
+	if e.IsAnonymous {
+		username = "Anonymous"
+	}
+	return bus.Cheer{User: username, Bits: e.Bits, Message: e.Message, Anonymous: e.IsAnonymous}
+}
+
+func (e RedemptionEvent) busEvent() bus.Event {
+	return bus.Redemption{User: e.UserName, Reward: e.Reward.Title, Cost: e.Reward.Cost, Input: e.UserInput}
+}
+
+func (e HypeTrainBeginEvent) busEvent() bus.Event {
+	return bus.Activity{Type: "channel.hype_train.begin", Text: fmt.Sprintf("Hype Train started! Level %d, %d/%d", e.Level, e.Progress, e.Goal)}
+}
+
+func (e HypeTrainProgressEvent) busEvent() bus.Event {
+	return bus.Activity{Type: "channel.hype_train.progress", Text: fmt.Sprintf("Hype Train level %d: %d/%d", e.Level, e.Progress, e.Goal)}
+}
+
+func (e HypeTrainEndEvent) busEvent() bus.Event {
+	return bus.Activity{Type: "channel.hype_train.end", Text: fmt.Sprintf("Hype Train ended at level %d with %d points!", e.Level, e.Total)}
+}
+
+func (e PollBeginEvent) busEvent() bus.Event {
+	return bus.Activity{Type: "channel.poll.begin", Text: fmt.Sprintf("Poll started: %s (%s)", e.Title, pollChoices(e.Choices))}
+}
+
+func (e PollProgressEvent) busEvent() bus.Event {
+	return bus.Activity{Type: "channel.poll.progress", Text: fmt.Sprintf("Poll %s: %s", e.Title, pollChoices(e.Choices))}
+}
+
+func (e PollEndEvent) busEvent() bus.Event {
+	return bus.Activity{Type: "channel.poll.end", Text: fmt.Sprintf("Poll %s %s: %s", e.Title, e.Status, pollChoices(e.Choices))}
+}
+
+func (e PredictionBeginEvent) busEvent() bus.Event {
+	return bus.Activity{Type: "channel.prediction.begin", Text: fmt.Sprintf("Prediction started: %s (%s)", e.Title, predictionOutcomes(e.Outcomes))}
+}
+
+func (e PredictionProgressEvent) busEvent() bus.Event {
+	return bus.Activity{Type: "channel.prediction.progress", Text: fmt.Sprintf("Prediction %s: %s", e.Title, predictionOutcomes(e.Outcomes))}
+}
+
+func (e PredictionLockEvent) busEvent() bus.Event {
+	return bus.Activity{Type: "channel.prediction.lock", Text: fmt.Sprintf("Prediction locked: %s (%s)", e.Title, predictionOutcomes(e.Outcomes))}
+}
+
+func (e PredictionEndEvent) busEvent() bus.Event {
+	text := fmt.Sprintf("Prediction %s %s", e.Title, e.Status)
+	for _, outcome := range e.Outcomes {
+		if outcome.ID == e.WinningOutcomeID {
+			text = fmt.Sprintf("Prediction %s: %s won!", e.Title, outcome.Title)
+		}
+	}
+	return bus.Activity{Type: "channel.prediction.end", Text: text}
+}
+
+func (e AdBreakEvent) busEvent() bus.Event {
+	kind := "Manual"
+	if e.IsAutomatic {
+		kind = "Automatic"
+	}
+	return bus.Activity{Type: "channel.ad_break.begin", Text: fmt.Sprintf("%s ad break started for %d seconds", kind, e.DurationSeconds)}
+}
+
+func (e ShoutoutCreateEvent) busEvent() bus.Event {
+	return bus.Activity{Type: "channel.shoutout.create", Text: fmt.Sprintf("Shoutout sent to %s", e.ToBroadcasterUserName)}
+}
+
+func (e ShoutoutReceiveEvent) busEvent() bus.Event {
+	return bus.Activity{Type: "channel.shoutout.receive", Text: fmt.Sprintf("%s gave you a shoutout!", e.FromBroadcasterUserName)}
+}
+
+// tierName turns Twitch's tier codes ("1000", "2000", "3000") into display names.
+func tierName(tier string) string {
+	switch tier {
+	case "2000":
+		return "Tier 2"
+	case "3000":
+		return "Tier 3"
+	default:
+		return "Tier 1"
+	}
+}
+
+func pollChoices(choices []PollChoice) string {
+	parts := make([]string, len(choices))
+	for i, choice := range choices {
+		parts[i] = fmt.Sprintf("%s: %d", choice.Title, choice.Votes)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func predictionOutcomes(outcomes []PredictionOutcome) string {
+	parts := make([]string, len(outcomes))
+	for i, outcome := range outcomes {
+		parts[i] = fmt.Sprintf("%s: %d pts", outcome.Title, outcome.ChannelPoints)
+	}
+	return strings.Join(parts, ", ")
+}
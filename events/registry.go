@@ -0,0 +1,190 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"argus/config"
+)
+
+// Condition fields used by the supported subscription types.
+const (
+	ConditionBroadcaster   = "broadcaster_user_id"
+	ConditionModerator     = "moderator_user_id"
+	ConditionToBroadcaster = "to_broadcaster_user_id"
+)
+
+// EventType describes an EventSub subscription type Argus can subscribe to and render.
+type EventType struct {
+	Type    string
+	Version string
+	// Condition lists the condition fields Twitch requires when creating the subscription.
+	Condition []string
+
+	decode func(json.RawMessage) (any, error)
+}
+
+// Registry lists every subscription type Argus understands.
+var Registry = []EventType{
+	{Type: "channel.follow", Version: "2", Condition: []string{ConditionBroadcaster, ConditionModerator}, decode: decodeEvent[FollowEvent]},
+	{Type: "channel.raid", Version: "1", Condition: []string{ConditionToBroadcaster}, decode: decodeEvent[RaidEvent]},
+	{Type: "channel.subscribe", Version: "1", Condition: []string{ConditionBroadcaster}, decode: decodeEvent[SubscribeEvent]},
+	{Type: "channel.subscription.gift", Version: "1", Condition: []string{ConditionBroadcaster}, decode: decodeEvent[GiftSubEvent]},
+	{Type: "channel.subscription.message", Version: "1", Condition: []string{ConditionBroadcaster}, decode: decodeEvent[ResubEvent]},
+	{Type: "channel.cheer", Version: "1", Condition: []string{ConditionBroadcaster}, decode: decodeEvent[CheerEvent]},
+	{Type: "channel.channel_points_custom_reward_redemption.add", Version: "1", Condition: []string{ConditionBroadcaster}, decode: decodeEvent[RedemptionEvent]},
+	{Type: "channel.hype_train.begin", Version: "1", Condition: []string{ConditionBroadcaster}, decode: decodeEvent[HypeTrainBeginEvent]},
+	{Type: "channel.hype_train.progress", Version: "1", Condition: []string{ConditionBroadcaster}, decode: decodeEvent[HypeTrainProgressEvent]},
+	{Type: "channel.hype_train.end", Version: "1", Condition: []string{ConditionBroadcaster}, decode: decodeEvent[HypeTrainEndEvent]},
+	{Type: "channel.poll.begin", Version: "1", Condition: []string{ConditionBroadcaster}, decode: decodeEvent[PollBeginEvent]},
+	{Type: "channel.poll.progress", Version: "1", Condition: []string{ConditionBroadcaster}, decode: decodeEvent[PollProgressEvent]},
+	{Type: "channel.poll.end", Version: "1", Condition: []string{ConditionBroadcaster}, decode: decodeEvent[PollEndEvent]},
+	{Type: "channel.prediction.begin", Version: "1", Condition: []string{ConditionBroadcaster}, decode: decodeEvent[PredictionBeginEvent]},
+	{Type: "channel.prediction.progress", Version: "1", Condition: []string{ConditionBroadcaster}, decode: decodeEvent[PredictionProgressEvent]},
+	{Type: "channel.prediction.lock", Version: "1", Condition: []string{ConditionBroadcaster}, decode: decodeEvent[PredictionLockEvent]},
+	{Type: "channel.prediction.end", Version: "1", Condition: []string{ConditionBroadcaster}, decode: decodeEvent[PredictionEndEvent]},
+	{Type: "channel.ad_break.begin", Version: "1", Condition: []string{ConditionBroadcaster}, decode: decodeEvent[AdBreakEvent]},
+	{Type: "channel.shoutout.create", Version: "1", Condition: []string{ConditionBroadcaster, ConditionModerator}, decode: decodeEvent[ShoutoutCreateEvent]},
+	{Type: "channel.shoutout.receive", Version: "1", Condition: []string{ConditionBroadcaster, ConditionModerator}, decode: decodeEvent[ShoutoutReceiveEvent]},
+}
+
+// LookupEventType finds the registry entry for a subscription type and version.
+func LookupEventType(subscriptionType, version string) (EventType, bool) {
+	i := slices.IndexFunc(Registry, func(t EventType) bool {
+		return t.Type == subscriptionType && t.Version == version
+	})
+	if i < 0 {
+		return EventType{}, false
+	}
+	return Registry[i], true
+}
+
+// ResolveEventTypes turns the EVENTSUB_TYPES and EVENTSUB_DISABLED settings into the list
+// of types to subscribe to. Entries are "type" or "type@version"; without a version the
+// newest registered one is used. With no EVENTSUB_TYPES every registered type is enabled.
+func ResolveEventTypes(cfg config.Config) ([]EventType, error) {
+	var problems []string
+
+	var selected []EventType
+	if len(cfg.EventTypes) == 0 {
+		for _, t := range Registry {
+			if latest, _ := latestEventType(t.Type); latest.Version == t.Version {
+				selected = append(selected, t)
+			}
+		}
+	} else {
+		for _, spec := range cfg.EventTypes {
+			t, ok := lookupSpec(spec)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("unknown EventSub type %q", spec))
+				continue
+			}
+			if slices.ContainsFunc(selected, func(s EventType) bool { return s.Type == t.Type }) {
+				problems = append(problems, fmt.Sprintf("EventSub type %q listed more than once", t.Type))
+				continue
+			}
+			selected = append(selected, t)
+		}
+	}
+
+	for _, name := range cfg.DisabledEvents {
+		if _, ok := latestEventType(name); !ok {
+			problems = append(problems, fmt.Sprintf("unknown EventSub type %q in EVENTSUB_DISABLED", name))
+		}
+	}
+
+	var enabled []EventType
+	for _, t := range selected {
+		if slices.Contains(cfg.DisabledEvents, t.Type) {
+			continue
+		}
+		for _, field := range t.Condition {
+			if conditionValue(field, cfg.ChannelID, cfg.UserID) == "" {
+				problems = append(problems, fmt.Sprintf("%s requires %s, which is not configured", t.Type, field))
+			}
+		}
+		enabled = append(enabled, t)
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+	return enabled, nil
+}
+
+// ResolveChannelEventTypes returns the subscription types created for every channel in
+// cfg.Channels besides the first. Their broadcaster IDs may only be known once they are
+// looked up, so only the moderator condition can be checked here.
+func ResolveChannelEventTypes(cfg config.Config) ([]EventType, error) {
+	var problems []string
+	var types []EventType
+	for _, spec := range cfg.ChannelEventTypes {
+		t, ok := lookupSpec(spec)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown EventSub type %q in EVENTSUB_CHANNEL_TYPES", spec))
+			continue
+		}
+		if slices.Contains(t.Condition, ConditionModerator) && cfg.UserID == "" {
+			problems = append(problems, fmt.Sprintf("%s requires %s, which is not configured", t.Type, ConditionModerator))
+		}
+		types = append(types, t)
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+	return types, nil
+}
+
+// lookupSpec finds a subscription type given as "type" or "type@version".
+func lookupSpec(spec string) (EventType, bool) {
+	name, version, pinned := strings.Cut(spec, "@")
+	if pinned {
+		return LookupEventType(name, version)
+	}
+	return latestEventType(name)
+}
+
+// latestEventType finds the newest registered version of a subscription type.
+func latestEventType(subscriptionType string) (EventType, bool) {
+	var latest EventType
+	found := false
+	for _, t := range Registry {
+		if t.Type == subscriptionType && (!found || versionNumber(t.Version) > versionNumber(latest.Version)) {
+			latest, found = t, true
+		}
+	}
+	return latest, found
+}
+
+// versionNumber orders subscription versions; non-numeric ones such as "beta" sort first.
+func versionNumber(version string) int {
+	n, _ := strconv.Atoi(version)
+	return n
+}
+
+// condition builds the subscription condition for the channel of broadcasterID, as
+// seen by the account userID.
+func (t EventType) condition(broadcasterID, userID string) map[string]string {
+	condition := make(map[string]string, len(t.Condition))
+	for _, field := range t.Condition {
+		condition[field] = conditionValue(field, broadcasterID, userID)
+	}
+	return condition
+}
+
+// conditionValue returns the user ID that goes in a condition field.
+func conditionValue(field, broadcasterID, userID string) string {
+	switch field {
+	case ConditionBroadcaster, ConditionToBroadcaster:
+		return broadcasterID
+	case ConditionModerator:
+		return userID
+	default:
+		return ""
+	}
+}
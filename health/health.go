@@ -0,0 +1,65 @@
+package health
+
+import (
+	"maps"
+	"sync"
+	"time"
+)
+
+// Component states reported by the long-running connections.
+const (
+	StateConnecting   = "connecting"
+	StateConnected    = "connected"
+	StateReconnecting = "reconnecting"
+	StateDisconnected = "disconnected"
+)
+
+// Status is the last known condition of one component.
+type Status struct {
+	State        string    `json:"state"`
+	Error        string    `json:"error,omitempty"`
+	LastActivity time.Time `json:"last_activity,omitzero"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Healthy reports whether the component is currently connected.
+func (s Status) Healthy() bool {
+	return s.State == StateConnected
+}
+
+var (
+	mu         sync.Mutex
+	components = make(map[string]Status)
+)
+
+// Set records a state change for the named component.
+func Set(name, state string, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	status := components[name]
+	status.State = state
+	status.Error = ""
+	if err != nil {
+		status.Error = err.Error()
+	}
+	status.UpdatedAt = time.Now()
+	components[name] = status
+}
+
+// Touch records that the named component just received data.
+func Touch(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	status := components[name]
+	status.LastActivity = time.Now()
+	components[name] = status
+}
+
+// Snapshot returns a copy of every component's status.
+func Snapshot() map[string]Status {
+	mu.Lock()
+	defer mu.Unlock()
+	return maps.Clone(components)
+}
@@ -0,0 +1,142 @@
+package helix
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// API_URL is the base of the Twitch Helix API.
+const API_URL = "https://api.twitch.tv/helix"
+
+// httpClient bounds every request so a stalled Helix call can't block its caller forever.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Client makes authenticated requests to the Twitch Helix API.
+type Client struct {
+	ClientID string
+	Token    string
+
+	// BaseURL defaults to API_URL.
+	BaseURL string
+}
+
+// NewClient creates a Helix client authenticated with token.
+func NewClient(clientID, token string) *Client {
+	return &Client{ClientID: clientID, Token: token, BaseURL: API_URL}
+}
+
+// get performs a GET request and decodes the JSON response into out.
+func (c *Client) get(path string, query url.Values, out any) error {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Add("Client-ID", c.ClientID)
+	req.Header.Add("Authorization", "Bearer "+c.Token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("GET %s: status: %s, body: %s", path, resp.Status, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding %s response: %w", path, err)
+	}
+	return nil
+}
+
+// BadgeSet is a chat badge and all of its versions.
+type BadgeSet struct {
+	SetID    string         `json:"set_id"`
+	Versions []BadgeVersion `json:"versions"`
+}
+
+// BadgeVersion is one image of a badge, such as a specific subscriber tenure.
+type BadgeVersion struct {
+	ID         string `json:"id"`
+	ImageURL1x string `json:"image_url_1x"`
+	ImageURL2x string `json:"image_url_2x"`
+	ImageURL4x string `json:"image_url_4x"`
+	Title      string `json:"title"`
+}
+
+// GlobalChatBadges returns the badges available in every channel.
+func (c *Client) GlobalChatBadges() ([]BadgeSet, error) {
+	var resp struct {
+		Data []BadgeSet `json:"data"`
+	}
+	if err := c.get("/chat/badges/global", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// ChannelChatBadges returns the custom badges of a channel, such as its subscriber badges.
+func (c *Client) ChannelChatBadges(broadcasterID string) ([]BadgeSet, error) {
+	var resp struct {
+		Data []BadgeSet `json:"data"`
+	}
+	if err := c.get("/chat/badges", url.Values{"broadcaster_id": {broadcasterID}}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// Stream is a live stream.
+type Stream struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	UserLogin string    `json:"user_login"`
+	GameName  string    `json:"game_name"`
+	Title     string    `json:"title"`
+	Viewers   int       `json:"viewer_count"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// StreamByLogin returns the live stream of a broadcaster, or nil if they are offline.
+func (c *Client) StreamByLogin(login string) (*Stream, error) {
+	var resp struct {
+		Data []Stream `json:"data"`
+	}
+	if err := c.get("/streams", url.Values{"user_login": {login}}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, nil
+	}
+	return &resp.Data[0], nil
+}
+
+// User is a Twitch account.
+type User struct {
+	ID          string `json:"id"`
+	Login       string `json:"login"`
+	DisplayName string `json:"display_name"`
+}
+
+// UsersByLogin looks up accounts by their login names. Logins that don't exist are
+// left out of the result.
+func (c *Client) UsersByLogin(logins ...string) ([]User, error) {
+	var resp struct {
+		Data []User `json:"data"`
+	}
+	if err := c.get("/users", url.Values{"login": logins}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
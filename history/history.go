@@ -0,0 +1,174 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"argus/bus"
+	"argus/services"
+)
+
+// maxEntries is how many of the most recent tracks are kept in memory.
+const maxEntries = 500
+
+// Entry is one track that started playing.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Name   string    `json:"name"`
+	Artist string    `json:"artist"`
+	Album  string    `json:"album,omitempty"`
+	URL    string    `json:"url,omitempty"`
+	Player string    `json:"player,omitempty"`
+}
+
+// String formats the entry the way it is shown in chat.
+func (e Entry) String() string {
+	if e.Artist == "" {
+		return e.Name
+	}
+	return e.Artist + " - " + e.Name
+}
+
+// History records every track that starts playing in a JSON Lines file, so it
+// survives restarts.
+type History struct {
+	path string
+
+	mu      sync.Mutex
+	entries []Entry
+	// playing is the track playing right now, or nil.
+	playing *services.Track
+}
+
+// Open loads the history stored at path, creating the file on the first write.
+func Open(path string) (*History, error) {
+	h := &History{path: path}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			log.Printf("Skipping invalid history line: %v", err)
+			continue
+		}
+		h.entries = append(h.entries, entry)
+		if len(h.entries) > 2*maxEntries {
+			h.entries = slices.Clone(h.entries[len(h.entries)-maxEntries:])
+		}
+	}
+	if len(h.entries) > maxEntries {
+		h.entries = h.entries[len(h.entries)-maxEntries:]
+	}
+	return h, scanner.Err()
+}
+
+// Run records track changes published on b until the bus subscription is closed.
+func (h *History) Run(b *bus.Bus) {
+	events, _ := b.Subscribe(64)
+	for event := range events {
+		if e, ok := event.(bus.TrackChanged); ok {
+			h.trackChanged(e.Data)
+		}
+	}
+}
+
+// trackChanged records data when it starts a different track. Pausing and resuming the
+// same track is not a new entry, but playing it again after stopping is.
+func (h *History) trackChanged(data services.NowPlayingData) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if data.Item == nil || data.Status == services.StatusStopped {
+		h.playing = nil
+		return
+	}
+	if !data.IsPlaying || sameSong(h.playing, data.Item) {
+		return
+	}
+	h.playing = data.Item
+
+	entry := Entry{
+		Time:   time.Now(),
+		Name:   data.Item.Name,
+		Artist: data.Item.ArtistNames(),
+		Album:  data.Item.Album,
+		URL:    data.Item.URL,
+		Player: data.Player,
+	}
+	if err := h.append(entry); err != nil {
+		log.Printf("Error writing song history: %v", err)
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > maxEntries {
+		h.entries = slices.Clone(h.entries[1:])
+	}
+}
+
+// append writes entry to the end of the history file.
+func (h *History) append(entry Entry) error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// Recent returns up to n entries, newest first.
+func (h *History) Recent(n int) []Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n = min(n, len(h.entries))
+	recent := slices.Clone(h.entries[len(h.entries)-n:])
+	slices.Reverse(recent)
+	return recent
+}
+
+// Last returns the track that played before the current one, or the most recent
+// track when nothing is playing.
+func (h *History) Last() (Entry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i := len(h.entries) - 1
+	if h.playing != nil {
+		i--
+	}
+	if i < 0 {
+		return Entry{}, false
+	}
+	return h.entries[i], true
+}
+
+// sameSong reports whether a and b are the same song, ignoring play state.
+func sameSong(a, b *services.Track) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Name == b.Name && a.Album == b.Album && slices.Equal(a.Artists, b.Artists)
+}
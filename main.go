@@ -2,31 +2,100 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"argus/bus"
 	"argus/chat"
 	"argus/config"
 	"argus/events"
+	"argus/helix"
+	"argus/history"
+	"argus/services"
+	"argus/terminal"
 	"argus/web"
 )
 
 func main() {
 	cfg := config.Load()
 
-	// Start the web server in its own goroutine.
-	go web.StartServer(cfg)
+	// Everything chat, EventSub and the music player produce goes through the bus.
+	eventBus := bus.New()
+	go terminal.Run(eventBus, cfg)
 
 	// Use a channel to wait for a termination signal.
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
+	// Watch the music player for track changes and progress.
+	stop := make(chan struct{})
+	nowPlaying, err := services.NewNowPlayingService(cfg)
+	if err != nil {
+		log.Fatalf("Invalid now playing configuration: %v", err)
+	}
+	poller := services.NewPoller(nowPlaying, time.Second)
+	poller.OnUpdate = func(data services.NowPlayingData, changed bool) {
+		if changed {
+			eventBus.Publish(bus.TrackChanged{Data: data})
+		} else {
+			eventBus.Publish(bus.TrackProgress{Data: data})
+		}
+	}
+	go poller.Run(stop)
+
+	// Keep a log of every song that plays.
+	songs, err := history.Open(cfg.HistoryPath)
+	if err != nil {
+		log.Fatalf("Error loading song history: %v", err)
+	}
+	go songs.Run(eventBus)
+
+	eventsClient, err := events.NewClient(cfg, eventBus)
+	if err != nil {
+		log.Fatalf("Invalid EventSub configuration: %v", err)
+	}
+
+	// Start the web server in its own goroutine. The chat overlay shows each channel's
+	// badges using the broadcaster IDs EventSub looks up.
+	go web.StartServer(cfg, eventBus, poller, songs, eventsClient.ChannelID)
+
+	// Answer chat commands.
+	customCommands, err := chat.LoadTextCommands(cfg.CommandsPath)
+	if err != nil {
+		log.Fatalf("Invalid chat commands: %v", err)
+	}
+	// Commands are only answered in our own channel, not in channels we just watch.
+	router := chat.NewRouter()
+	router.Channels = []string{cfg.Channel}
+	router.Register(
+		chat.SongCommand(poller.Current),
+		chat.LastSongCommand(songs),
+		chat.UptimeCommand(helix.NewClient(cfg.ClientID, cfg.AppAccessToken)),
+	)
+	router.Register(customCommands...)
+	go router.Run(eventBus)
+
 	// Run chat and Events concurrently.
-	go chat.Connect(cfg)
-	go events.Run(cfg)
+	chatClient := chat.NewClient(cfg, eventBus)
+	go chatClient.Run()
+	go eventsClient.Run()
+
+	// Whatever the streamer types in the terminal is sent to chat.
+	restoreTerminal := terminal.StartInput(chatClient, cfg.Channel, func() {
+		select {
+		case sigs <- os.Interrupt:
+		default:
+		}
+	})
 
 	// Wait for a termination signal to close the program.
 	<-sigs
+	restoreTerminal()
 	fmt.Println("\nProgram terminated. Disconnecting...")
+	close(stop)
+	chatClient.Close()
+	eventsClient.Close()
 }
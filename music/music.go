@@ -1,6 +1,7 @@
 package music
 
 import (
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -9,12 +10,19 @@ import (
 // GetNowPlayingInfo retrieves the currently playing media information.
 func GetNowPlayingInfo(tool string, args ...string) (string, error) {
 	cmd := exec.Command(tool, args...)
-	output, err := cmd.CombinedOutput()
+	// Only stdout is parsed; warnings on stderr would otherwise end up in the track info.
+	output, err := cmd.Output()
 	if err != nil {
-		return "", fmt.Errorf("failed to execute command '%s %s': %w\nOutput: %s", tool, strings.Join(args, " "), err, string(output))
+		var stderr []byte
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			stderr = exitErr.Stderr
+		}
+		return "", fmt.Errorf("failed to execute command '%s %s': %w\nOutput: %s", tool, strings.Join(args, " "), err, string(stderr))
 	}
 
-	trackInfo := strings.TrimSpace(string(output))
+	// Only the trailing newline is dropped: line-oriented output may start with an empty value.
+	trackInfo := strings.TrimRight(string(output), "\r\n")
 	return trackInfo, nil
 }
 
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"argus/backoff"
+	"argus/config"
+)
+
+// MPDProvider talks to the Music Player Daemon directly over its text protocol.
+type MPDProvider struct {
+	// Network and Address say where MPD listens: "tcp" and "host:port", or "unix" and a socket path.
+	Network  string
+	Address  string
+	Password string
+}
+
+// NewMPDProvider creates a provider for the MPD instance described by MPD_HOST and MPD_PORT.
+// Like mpc, MPD_HOST may be a socket path and may carry a password as "password@host".
+func NewMPDProvider(cfg config.Config) *MPDProvider {
+	host, port := cfg.MPDHost, cfg.MPDPort
+	password := cfg.MPDPassword
+	if pw, h, ok := strings.Cut(host, "@"); ok && pw != "" && h != "" {
+		password, host = pw, h
+	}
+
+	p := &MPDProvider{Network: "tcp", Password: password}
+	if strings.HasPrefix(host, "/") {
+		p.Network, p.Address = "unix", host
+	} else {
+		p.Address = net.JoinHostPort(host, port)
+	}
+	return p
+}
+
+// Name implements Provider.
+func (p *MPDProvider) Name() string {
+	return "mpd"
+}
+
+// Available implements Provider. It only checks the configuration: MPD may start after
+// Argus does, and Watch and Current keep reconnecting until it is up.
+func (p *MPDProvider) Available() error {
+	switch p.Network {
+	case "unix":
+		if p.Address == "" {
+			return errors.New("MPD socket path is empty")
+		}
+	case "tcp":
+		if _, _, err := net.SplitHostPort(p.Address); err != nil {
+			return fmt.Errorf("invalid MPD address %q: %w", p.Address, err)
+		}
+	default:
+		return fmt.Errorf("unsupported MPD network %q", p.Network)
+	}
+	return nil
+}
+
+// Current implements Provider.
+func (p *MPDProvider) Current() (NowPlayingData, error) {
+	conn, err := p.connect()
+	if err != nil {
+		return NowPlayingData{}, err
+	}
+	defer conn.Close()
+	return conn.nowPlaying()
+}
+
+// Watch implements Watcher using MPD's idle command, which blocks until the player changes.
+func (p *MPDProvider) Watch(stop <-chan struct{}, update func(NowPlayingData)) {
+	retry := backoff.New(time.Second, time.Minute)
+	for {
+		err := p.watch(stop, update, retry)
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		delay := retry.Next()
+		log.Printf("MPD connection lost: %v. Reconnecting in %s", err, delay.Round(time.Millisecond))
+		update(NotPlaying())
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (p *MPDProvider) watch(stop <-chan struct{}, update func(NowPlayingData), retry *backoff.Backoff) error {
+	conn, err := p.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Closing the connection is the only way to interrupt a pending idle.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stop:
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	retry.Reset()
+	for {
+		data, err := conn.nowPlaying()
+		if err != nil {
+			return err
+		}
+		update(data)
+
+		// idle has no timeout, so clear the deadline connect set.
+		conn.SetDeadline(time.Time{})
+		if _, err := conn.command("idle player"); err != nil {
+			return err
+		}
+	}
+}
+
+// mpdConn is a connection that has passed MPD's greeting and authentication.
+type mpdConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// connect dials MPD, checks its greeting and sends the password if there is one.
+func (p *MPDProvider) connect() (*mpdConn, error) {
+	c, err := net.DialTimeout(p.Network, p.Address, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to MPD at %s: %w", p.Address, err)
+	}
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+
+	conn := &mpdConn{Conn: c, reader: bufio.NewReader(c)}
+	greeting, err := conn.reader.ReadString('\n')
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("reading MPD greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "OK MPD ") {
+		c.Close()
+		return nil, fmt.Errorf("unexpected MPD greeting %q", strings.TrimSpace(greeting))
+	}
+
+	if p.Password != "" {
+		if _, err := conn.command("password " + quoteMPD(p.Password)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// command sends one command and returns the key/value pairs of the response. Keys that
+// appear more than once, like Artist, keep every value in order.
+func (c *mpdConn) command(cmd string) ([][2]string, error) {
+	if _, err := fmt.Fprintf(c, "%s\n", cmd); err != nil {
+		return nil, err
+	}
+
+	var pairs [][2]string
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\n")
+
+		if line == "OK" {
+			return pairs, nil
+		}
+		if strings.HasPrefix(line, "ACK ") {
+			return nil, errors.New("MPD: " + strings.TrimPrefix(line, "ACK "))
+		}
+		if key, value, ok := strings.Cut(line, ": "); ok {
+			pairs = append(pairs, [2]string{key, value})
+		}
+	}
+}
+
+// nowPlaying reads the player status and current song.
+func (c *mpdConn) nowPlaying() (NowPlayingData, error) {
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+
+	status, err := c.command("status")
+	if err != nil {
+		return NowPlayingData{}, err
+	}
+	song, err := c.command("currentsong")
+	if err != nil {
+		return NowPlayingData{}, err
+	}
+	return parseMPD(status, song), nil
+}
+
+// parseMPD builds now playing data from the responses to status and currentsong.
+func parseMPD(status, song [][2]string) NowPlayingData {
+	state := mpdValue(status, "state")
+	if state == "stop" || len(song) == 0 {
+		return NotPlaying()
+	}
+
+	track := &Track{
+		Name:    mpdValue(song, "Title"),
+		Artists: []Artist{},
+		Album:   mpdValue(song, "Album"),
+		URL:     mpdValue(song, "file"),
+	}
+	if track.Name == "" {
+		// Streams often only have a Name, and untagged files only a path.
+		track.Name = mpdValue(song, "Name")
+	}
+	if track.Name == "" {
+		track.Name = path.Base(mpdValue(song, "file"))
+	}
+	for _, pair := range song {
+		if pair[0] == "Artist" {
+			track.Artists = append(track.Artists, Artist{Name: pair[1]})
+		}
+	}
+
+	track.DurationMs = secondsToMs(mpdValue(status, "duration"))
+	if track.DurationMs == 0 {
+		track.DurationMs = secondsToMs(mpdValue(song, "duration"))
+	}
+
+	playback := StatusPaused
+	if state == "play" {
+		playback = StatusPlaying
+	}
+
+	return NowPlayingData{
+		IsPlaying:  state == "play",
+		Status:     playback,
+		ProgressMs: secondsToMs(mpdValue(status, "elapsed")),
+		Item:       track,
+		Player:     "mpd",
+	}
+}
+
+// mpdValue returns the first value for key.
+func mpdValue(pairs [][2]string, key string) string {
+	for _, pair := range pairs {
+		if pair[0] == key {
+			return pair[1]
+		}
+	}
+	return ""
+}
+
+// secondsToMs converts MPD's fractional seconds to milliseconds.
+func secondsToMs(s string) int64 {
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(seconds * 1000)
+}
+
+// quoteMPD quotes an argument for the MPD protocol.
+func quoteMPD(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"argus/config"
+)
+
+// fakeMPD serves the handful of MPD commands the provider uses.
+type fakeMPD struct {
+	listener net.Listener
+	password string
+	// changed wakes up pending idle commands.
+	changed chan struct{}
+
+	mu       sync.Mutex
+	status   string
+	song     string
+	commands []string
+}
+
+func newFakeMPD(t *testing.T, password string) *fakeMPD {
+	t.Helper()
+	return listenFakeMPD(t, "tcp", "127.0.0.1:0", password)
+}
+
+func listenFakeMPD(t *testing.T, network, address, password string) *fakeMPD {
+	t.Helper()
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s := &fakeMPD{listener: listener, password: password, changed: make(chan struct{})}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// play sets the responses to status and currentsong.
+func (s *fakeMPD) play(status, song string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status, s.song = status, song
+}
+
+func (s *fakeMPD) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.commands)
+}
+
+func (s *fakeMPD) serve(conn net.Conn) {
+	defer conn.Close()
+	fmt.Fprint(conn, "OK MPD 0.23.5\n")
+
+	authorized := s.password == ""
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		cmd := scanner.Text()
+		s.mu.Lock()
+		s.commands = append(s.commands, cmd)
+		status, song := s.status, s.song
+		s.mu.Unlock()
+
+		switch {
+		case cmd == fmt.Sprintf("password %q", s.password):
+			authorized = true
+			fmt.Fprint(conn, "OK\n")
+		case !authorized:
+			fmt.Fprintf(conn, "ACK [4@0] {%s} you don't have permission for \"%s\"\n", cmd, cmd)
+		case cmd == "status":
+			fmt.Fprint(conn, status+"OK\n")
+		case cmd == "currentsong":
+			fmt.Fprint(conn, song+"OK\n")
+		case cmd == "idle player":
+			<-s.changed
+			fmt.Fprint(conn, "changed: player\nOK\n")
+		default:
+			fmt.Fprintf(conn, "ACK [5@0] {} unknown command \"%s\"\n", cmd)
+		}
+	}
+}
+
+const (
+	mpdPlaying = "volume: 100\nrepeat: 0\nstate: play\nsong: 3\nelapsed: 42.120\nduration: 262.373\n"
+	mpdPaused  = "volume: 100\nrepeat: 0\nstate: pause\nsong: 4\nelapsed: 3.500\nduration: 178.000\n"
+
+	mpdHelplessly = "file: csn/Helplessly Hoping.flac\nArtist: Crosby\nArtist: Stills\nArtist: Nash\n" +
+		"Title: Helplessly Hoping\nAlbum: Crosby, Stills & Nash\nTime: 162\nduration: 162.000\n"
+	mpdStandByMe = "file: Stand by Me.mp3\nArtist: Ben E. King\nTitle: Stand by Me\nAlbum: Don't Play That Song!\n"
+)
+
+func TestMPDCurrent(t *testing.T) {
+	server := newFakeMPD(t, "hunter2")
+	server.play(mpdPlaying, mpdHelplessly)
+	p := &MPDProvider{Network: "tcp", Address: server.listener.Addr().String(), Password: "hunter2"}
+
+	data, err := p.Current()
+	if err != nil {
+		t.Fatalf("Current() error = %v", err)
+	}
+	if !data.IsPlaying || data.Status != StatusPlaying || data.ProgressMs != 42120 || data.Player != "mpd" {
+		t.Errorf("Current() = %+v, want playing at 42120ms from mpd", data)
+	}
+	track := data.Item
+	if track.Name != "Helplessly Hoping" || track.Album != "Crosby, Stills & Nash" || track.DurationMs != 262373 {
+		t.Errorf("Current() track = %+v", track)
+	}
+	var artists []string
+	for _, artist := range track.Artists {
+		artists = append(artists, artist.Name)
+	}
+	if want := []string{"Crosby", "Stills", "Nash"}; !slices.Equal(artists, want) {
+		t.Errorf("Current() artists = %q, want %q", artists, want)
+	}
+
+	want := []string{`password "hunter2"`, "status", "currentsong"}
+	if got := server.received(); !slices.Equal(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestMPDWrongPassword(t *testing.T) {
+	server := newFakeMPD(t, "hunter2")
+	server.play(mpdPlaying, mpdHelplessly)
+	p := &MPDProvider{Network: "tcp", Address: server.listener.Addr().String(), Password: "wrong"}
+
+	if _, err := p.Current(); err == nil || !strings.Contains(err.Error(), "permission") {
+		t.Errorf("Current() error = %v, want a permission error", err)
+	}
+}
+
+func TestMPDWatch(t *testing.T) {
+	server := newFakeMPD(t, "")
+	server.play(mpdPlaying, mpdHelplessly)
+	p := &MPDProvider{Network: "tcp", Address: server.listener.Addr().String()}
+
+	updates := make(chan NowPlayingData, 8)
+	stop := make(chan struct{})
+	defer close(stop)
+	go p.Watch(stop, func(data NowPlayingData) { updates <- data })
+
+	next := func() NowPlayingData {
+		t.Helper()
+		select {
+		case data := <-updates:
+			return data
+		case <-time.After(5 * time.Second):
+			t.Fatal("no update from the watcher")
+			return NowPlayingData{}
+		}
+	}
+
+	if data := next(); data.Item == nil || data.Item.Name != "Helplessly Hoping" || !data.IsPlaying {
+		t.Fatalf("first update = %+v, want Helplessly Hoping playing", data)
+	}
+
+	server.play(mpdPaused, mpdStandByMe)
+	server.changed <- struct{}{}
+
+	data := next()
+	if data.Item == nil || data.Item.Name != "Stand by Me" || data.Status != StatusPaused || data.ProgressMs != 3500 {
+		t.Fatalf("update after changed: player = %+v, want Stand by Me paused at 3500ms", data)
+	}
+	if data.Item.DurationMs != 178000 {
+		t.Errorf("duration = %d, want 178000", data.Item.DurationMs)
+	}
+}
+
+func TestMPDWatchWaitsForServer(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "mpd.socket")
+	p := &MPDProvider{Network: "unix", Address: socket}
+	// MPD isn't running yet, which must not make the provider unusable.
+	if err := p.Available(); err != nil {
+		t.Fatalf("Available() error = %v, want nil before MPD is up", err)
+	}
+
+	updates := make(chan NowPlayingData, 8)
+	stop := make(chan struct{})
+	defer close(stop)
+	go p.Watch(stop, func(data NowPlayingData) { updates <- data })
+
+	select {
+	case data := <-updates:
+		if data.Status != StatusStopped {
+			t.Fatalf("update while MPD is down = %+v, want stopped", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no update while MPD is down")
+	}
+
+	server := listenFakeMPD(t, "unix", socket, "")
+	server.play(mpdPlaying, mpdStandByMe)
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case data := <-updates:
+			if data.Item != nil && data.Item.Name == "Stand by Me" {
+				return
+			}
+		case <-timeout:
+			t.Fatal("watcher never connected once MPD came up")
+		}
+	}
+}
+
+func TestNowPlayingServiceKeepsMPDWhileDown(t *testing.T) {
+	cfg := config.Config{NowPlayingProvider: "mpd", MPDHost: filepath.Join(t.TempDir(), "mpd.socket")}
+	service, err := NewNowPlayingService(cfg)
+	if err != nil {
+		t.Fatalf("NewNowPlayingService() error = %v", err)
+	}
+	if _, ok := service.Provider().(*MPDProvider); !ok {
+		t.Fatalf("Provider() = %T, want the MPD provider even though MPD is down", service.Provider())
+	}
+}
+
+func TestMPDAvailable(t *testing.T) {
+	tests := []struct {
+		provider MPDProvider
+		ok       bool
+	}{
+		{MPDProvider{Network: "tcp", Address: "localhost:6600"}, true},
+		{MPDProvider{Network: "unix", Address: "/run/mpd/socket"}, true},
+		{MPDProvider{Network: "tcp", Address: "localhost"}, false},
+		{MPDProvider{Network: "unix"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.provider.Available(); (err == nil) != tt.ok {
+			t.Errorf("Available(%s %q) error = %v, want ok %v", tt.provider.Network, tt.provider.Address, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"argus/config"
+	"argus/dependencies"
+)
+
+// NowPlayingCLIProvider reads the macOS now playing info through nowplaying-cli.
+type NowPlayingCLIProvider struct {
+	// Command is the nowplaying-cli binary to run.
+	Command string
+	// Run executes nowplaying-cli.
+	Run Runner
+}
+
+// NewNowPlayingCLIProvider creates a provider that runs nowplaying-cli from PATH.
+func NewNowPlayingCLIProvider(cfg config.Config) *NowPlayingCLIProvider {
+	return &NowPlayingCLIProvider{Command: "nowplaying-cli", Run: defaultRunner}
+}
+
+// Name implements Provider.
+func (p *NowPlayingCLIProvider) Name() string {
+	return "nowplaying-cli"
+}
+
+// Available implements Provider.
+func (p *NowPlayingCLIProvider) Available() error {
+	dep := dependencies.Dependency{Command: p.Command, OS: "darwin"}
+	if ok, errMessage := dep.Check(); !ok {
+		return errors.New(errMessage)
+	}
+	return nil
+}
+
+// nowPlayingCLIKeys are the keys asked from `nowplaying-cli get`, which prints one
+// value per line in the same order.
+var nowPlayingCLIKeys = []string{"title", "artist", "album", "duration", "elapsedTime", "playbackRate", "artworkData"}
+
+// Current implements Provider.
+func (p *NowPlayingCLIProvider) Current() (NowPlayingData, error) {
+	rawOutput, err := p.Run(p.Command, append([]string{"get"}, nowPlayingCLIKeys...)...)
+	if err != nil {
+		return NowPlayingData{}, err
+	}
+	return parseNowPlayingCLIOutput(rawOutput), nil
+}
+
+// parseNowPlayingCLIOutput parses the output of `nowplaying-cli get` for nowPlayingCLIKeys.
+// Keys without a value are printed as "null". Times are in seconds and artwork is base64.
+func parseNowPlayingCLIOutput(rawOutput string) NowPlayingData {
+	lines := strings.Split(rawOutput, "\n")
+	if len(lines) != len(nowPlayingCLIKeys) {
+		log.Printf("Parsing failed, expected %d lines but got %d.", len(nowPlayingCLIKeys), len(lines))
+		return NotPlaying()
+	}
+
+	values := make(map[string]string, len(lines))
+	for i, key := range nowPlayingCLIKeys {
+		if value := strings.TrimRight(lines[i], "\r"); value != "null" {
+			values[key] = value
+		}
+	}
+
+	if values["title"] == "" {
+		return NotPlaying()
+	}
+
+	artists := []Artist{}
+	if values["artist"] != "" {
+		artists = []Artist{{Name: values["artist"]}}
+	}
+
+	track := &Track{
+		Name:       values["title"],
+		Artists:    artists,
+		Album:      values["album"],
+		DurationMs: parseSeconds("duration", values["duration"]),
+	}
+
+	if artwork := values["artworkData"]; artwork != "" {
+		data, err := base64.StdEncoding.DecodeString(artwork)
+		if err != nil {
+			log.Printf("Error decoding artwork: %v", err)
+		} else {
+			sum := sha256.Sum256(data)
+			track.ArtData = data
+			track.ArtID = hex.EncodeToString(sum[:8])
+		}
+	}
+
+	// A rate of 0 means the player is paused; nowplaying-cli has no separate state.
+	status := StatusPaused
+	rate, err := strconv.ParseFloat(values["playbackRate"], 64)
+	if err == nil && rate > 0 {
+		status = StatusPlaying
+	} else {
+		rate = 0
+	}
+
+	return NowPlayingData{
+		IsPlaying:    status == StatusPlaying,
+		Status:       status,
+		ProgressMs:   parseSeconds("elapsed time", values["elapsedTime"]),
+		Item:         track,
+		PlaybackRate: rate,
+	}
+}
+
+// parseSeconds converts a value in seconds, such as "215.4", to milliseconds. Missing
+// values are 0.
+func parseSeconds(name, s string) int64 {
+	if s == "" {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		log.Printf("Error parsing %s: %v", name, err)
+		return 0
+	}
+	return int64(seconds * 1000)
+}
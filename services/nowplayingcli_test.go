@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseNowPlayingCLIOutputFixtures(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		status   string
+		progress int64
+		duration int64
+		rate     float64
+		artID    string
+	}{
+		{fixture: "playing.txt", status: StatusPlaying, progress: 12500, duration: 178200, rate: 1, artID: "02a3e298f1533f62"},
+		{fixture: "paused.txt", status: StatusPaused, progress: 83250, duration: 162000, rate: 0, artID: "02a3e298f1533f62"},
+		{fixture: "null.txt", status: StatusStopped},
+		{fixture: "bad-artwork.txt", status: StatusPlaying, progress: 500, duration: 178200, rate: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			raw, err := os.ReadFile(filepath.Join("testdata", "nowplaying-cli", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The runner strips the trailing newline the same way.
+			data := parseNowPlayingCLIOutput(strings.TrimRight(string(raw), "\r\n"))
+
+			if data.Status != tt.status || data.ProgressMs != tt.progress || data.PlaybackRate != tt.rate {
+				t.Errorf("status %q progress %d rate %v, want %q %d %v",
+					data.Status, data.ProgressMs, data.PlaybackRate, tt.status, tt.progress, tt.rate)
+			}
+			if tt.status == StatusStopped {
+				if data.Item != nil {
+					t.Errorf("Item = %+v, want nil", data.Item)
+				}
+				return
+			}
+			if data.Item.DurationMs != tt.duration || data.Item.ArtID != tt.artID {
+				t.Errorf("duration %d art %q, want %d %q", data.Item.DurationMs, data.Item.ArtID, tt.duration, tt.artID)
+			}
+			if tt.artID == "" && data.Item.ArtData != nil {
+				t.Errorf("ArtData = %d bytes, want none", len(data.Item.ArtData))
+			}
+		})
+	}
+}
+
+func TestNowPlayingCLICurrent(t *testing.T) {
+	args := "get title artist album duration elapsedTime playbackRate artworkData"
+	p := &NowPlayingCLIProvider{
+		Command: "nowplaying-cli",
+		Run: fakeRunner(map[string]string{
+			args: "Stand by Me\nBen E. King\nDon't Play That Song!\n178.2\n12.5\n1\nnull",
+		}),
+	}
+
+	data, err := p.Current()
+	if err != nil {
+		t.Fatalf("Current() error = %v", err)
+	}
+	if !data.IsPlaying || data.Item.Name != "Stand by Me" || data.ProgressMs != 12500 {
+		t.Errorf("Current() = %+v, want Stand by Me playing at 12500ms", data)
+	}
+}
+
+func TestNowPlayingCLICurrentError(t *testing.T) {
+	failure := errors.New("exit status 1")
+	p := &NowPlayingCLIProvider{
+		Command: "nowplaying-cli",
+		Run: func(tool string, args ...string) (string, error) {
+			return "", failure
+		},
+	}
+	if _, err := p.Current(); !errors.Is(err, failure) {
+		t.Errorf("Current() error = %v, want %v", err, failure)
+	}
+}
+
+func FuzzParseNowPlayingCLIOutput(f *testing.F) {
+	f.Add("Crosby; Stills", "Helplessly Hoping; Live")
+	f.Add("Ben E. King", "Stand by Me")
+	f.Fuzz(func(t *testing.T, artist, title string) {
+		output := strings.Join([]string{title, artist, "null", "178.2", "12.5", "1", "null"}, "\n")
+		data := parseNowPlayingCLIOutput(output)
+		// Titles that can't be told apart from another line or from a missing value
+		// aren't expected to survive.
+		if strings.ContainsAny(artist+title, "\n") || title == "" || title == "null" || strings.HasSuffix(title, "\r") {
+			return
+		}
+		if data.Item == nil || data.Item.Name != title {
+			t.Errorf("title = %+v, want %q", data.Item, title)
+		}
+	})
+}
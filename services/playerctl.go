@@ -0,0 +1,383 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"argus/backoff"
+	"argus/config"
+	"argus/dependencies"
+)
+
+// playerctlSeparator separates the fields of playerctlFormat. It is the ASCII unit
+// separator, which unlike ";" never shows up in titles, artists or URLs.
+const playerctlSeparator = "\x1f"
+
+// playerctlFields lists the fields of playerctlFormat, in order.
+var playerctlFields = []string{"{{status}}", "{{xesam:artist}}", "{{title}}", "{{position}}", "{{mpris:length}}", "{{xesam:album}}", "{{mpris:artUrl}}", "{{xesam:url}}"}
+
+// playerctlFormat is the metadata template passed to playerctl.
+var playerctlFormat = strings.Join(playerctlFields, playerctlSeparator)
+
+// PlayerctlProvider reads MPRIS players on Linux through playerctl.
+type PlayerctlProvider struct {
+	// Command is the playerctl binary to run.
+	Command string
+	// Run executes one-shot playerctl queries.
+	Run Runner
+
+	// Priority lists player name patterns in the order they are preferred.
+	Priority []string
+	// Exclude lists player name patterns that are never used.
+	Exclude []string
+	// AnyPlayer falls back to any other playing player when no preferred one is playing.
+	AnyPlayer bool
+
+	showLogs bool
+
+	mu         sync.Mutex
+	players    map[string]playerState
+	lastPlayer string
+}
+
+// playerState is the last reported state of one player while watching.
+type playerState struct {
+	data      NowPlayingData
+	updatedAt time.Time
+}
+
+// NewPlayerctlProvider creates a provider that runs playerctl from PATH.
+func NewPlayerctlProvider(cfg config.Config) *PlayerctlProvider {
+	return &PlayerctlProvider{
+		Command:   "playerctl",
+		Run:       defaultRunner,
+		Priority:  cfg.PlayerPriority,
+		Exclude:   cfg.PlayerExclude,
+		AnyPlayer: cfg.PlayerAnyFallback,
+		showLogs:  cfg.ShowLogs,
+		players:   make(map[string]playerState),
+	}
+}
+
+// Name implements Provider.
+func (p *PlayerctlProvider) Name() string {
+	return "playerctl"
+}
+
+// Available implements Provider.
+func (p *PlayerctlProvider) Available() error {
+	dep := dependencies.Dependency{Command: p.Command, OS: "linux"}
+	if ok, errMessage := dep.Check(); !ok {
+		return errors.New(errMessage)
+	}
+	return nil
+}
+
+// Current implements Provider by asking each running player in priority order for its
+// metadata. The first playing player wins; if none is playing, the first paused one is shown.
+func (p *PlayerctlProvider) Current() (NowPlayingData, error) {
+	// playerctl exits non-zero when there are no players at all.
+	running, err := p.Run(p.Command, "--list-all")
+	if err != nil {
+		p.choosePlayer("")
+		return NotPlaying(), nil
+	}
+
+	paused := NotPlaying()
+	for _, player := range p.orderPlayers(strings.Fields(running)) {
+		args := []string{"--player=" + player, "metadata", "--format", playerctlFormat}
+		output, err := p.Run(p.Command, args...)
+		if err != nil {
+			continue
+		}
+
+		data := parsePlayerctlOutput(output)
+		data.Player = player
+		if data.IsPlaying {
+			p.choosePlayer(player)
+			return p.withArtists(data), nil
+		}
+		if data.Status == StatusPaused && paused.Status != StatusPaused {
+			paused = data
+		}
+	}
+	p.choosePlayer(paused.Player)
+	return p.withArtists(paused), nil
+}
+
+// withArtists replaces the artists of data with every value of the player's xesam:artist
+// list. The --format output joins that list with ", ", which can't be split back apart,
+// but the metadata table prints each value on a row of its own. If that query fails, the
+// joined string is kept as a single artist.
+func (p *PlayerctlProvider) withArtists(data NowPlayingData) NowPlayingData {
+	if data.Item == nil || data.Player == "" {
+		return data
+	}
+	table, err := p.Run(p.Command, "--player="+data.Player, "metadata")
+	if err != nil {
+		return data
+	}
+
+	item := *data.Item
+	item.Artists = parsePlayerctlArtists(table)
+	data.Item = &item
+	return data
+}
+
+// parsePlayerctlArtists reads the xesam:artist rows of the table printed by
+// `playerctl metadata`, such as "spotify xesam:artist              Crosby".
+func parsePlayerctlArtists(table string) []Artist {
+	artists := []Artist{}
+	for line := range strings.SplitSeq(table, "\n") {
+		_, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+		key, value, _ := strings.Cut(strings.TrimLeft(rest, " "), " ")
+		if value = strings.TrimLeft(value, " "); key == "xesam:artist" && value != "" {
+			artists = append(artists, Artist{Name: value})
+		}
+	}
+	return artists
+}
+
+// orderPlayers drops excluded players and sorts the rest by priority. Players that match
+// no priority pattern are only kept when the any-player fallback is on.
+func (p *PlayerctlProvider) orderPlayers(names []string) []string {
+	var ordered []string
+	for _, pattern := range p.Priority {
+		for _, name := range names {
+			if matchPlayer(pattern, name) && !slices.Contains(ordered, name) && !p.excluded(name) {
+				ordered = append(ordered, name)
+			}
+		}
+	}
+	if p.AnyPlayer {
+		for _, name := range names {
+			if !slices.Contains(ordered, name) && !p.excluded(name) {
+				ordered = append(ordered, name)
+			}
+		}
+	}
+	return ordered
+}
+
+func (p *PlayerctlProvider) excluded(name string) bool {
+	return slices.ContainsFunc(p.Exclude, func(pattern string) bool { return matchPlayer(pattern, name) })
+}
+
+// matchPlayer matches a player name against a pattern such as "spotify" or "firefox*".
+// Patterns also match the name without its instance suffix, so "vlc" matches
+// "vlc.instance1234".
+func matchPlayer(pattern, name string) bool {
+	base, _, _ := strings.Cut(name, ".")
+	if ok, _ := path.Match(pattern, name); ok {
+		return true
+	}
+	ok, _ := path.Match(pattern, base)
+	return ok
+}
+
+// choosePlayer logs whenever a different player is picked.
+func (p *PlayerctlProvider) choosePlayer(name string) {
+	p.mu.Lock()
+	changed := name != p.lastPlayer
+	p.lastPlayer = name
+	p.mu.Unlock()
+
+	if changed && p.showLogs {
+		if name == "" {
+			log.Println("No player is playing")
+		} else {
+			log.Printf("Now playing from player %s", name)
+		}
+	}
+}
+
+// Watch implements Watcher. It follows every MPRIS player through one long-lived
+// `playerctl --follow` process, which prints a line whenever a player emits
+// PropertiesChanged, and restarts it with backoff whenever it exits.
+func (p *PlayerctlProvider) Watch(stop <-chan struct{}, update func(NowPlayingData)) {
+	retry := backoff.New(time.Second, time.Minute)
+	for {
+		started := time.Now()
+		err := p.follow(stop, update)
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		// A process that ran for a while was healthy; don't penalize the next start.
+		if time.Since(started) > time.Minute {
+			retry.Reset()
+		}
+		delay := retry.Next()
+		log.Printf("playerctl watcher stopped: %v. Restarting in %s", err, delay.Round(time.Millisecond))
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// follow runs a single playerctl --follow process until it exits or stop is closed.
+func (p *PlayerctlProvider) follow(stop <-chan struct{}, update func(NowPlayingData)) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	cmd := exec.CommandContext(ctx, p.Command, "--all-players", "--follow", "metadata", "--format", "{{playerInstance}}"+playerctlSeparator+playerctlFormat)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting %s: %w", p.Command, err)
+	}
+
+	// Players that were running before we started may have gone away in the meantime.
+	p.mu.Lock()
+	clear(p.players)
+	p.mu.Unlock()
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			// playerctl prints an empty line when a player exits, without saying which one.
+			p.prunePlayers()
+		} else if name, rest, ok := strings.Cut(line, playerctlSeparator); ok {
+			data := parsePlayerctlOutput(rest)
+			data.Player = name
+			data = p.withArtists(data)
+			p.mu.Lock()
+			p.players[name] = playerState{data: data, updatedAt: time.Now()}
+			p.mu.Unlock()
+		}
+		data := p.selectPlayer()
+		p.choosePlayer(data.Player)
+		update(data)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return err
+	}
+	return scanner.Err()
+}
+
+// selectPlayer returns the first playing player in priority order, with its progress
+// advanced by the time that passed since playerctl last reported it. If none is playing,
+// the first paused player is returned.
+func (p *PlayerctlProvider) selectPlayer() NowPlayingData {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	paused := NotPlaying()
+	names := slices.Sorted(maps.Keys(p.players))
+	for _, name := range p.orderPlayers(names) {
+		state := p.players[name]
+		data := state.data
+		data.Player = name
+		if data.IsPlaying {
+			data.ProgressMs += time.Since(state.updatedAt).Milliseconds()
+			return data
+		}
+		if data.Status == StatusPaused && paused.Status != StatusPaused {
+			paused = data
+		}
+	}
+	return paused
+}
+
+// prunePlayers drops every player playerctl no longer lists.
+func (p *PlayerctlProvider) prunePlayers() {
+	// playerctl exits non-zero when no players are left, which leaves running empty.
+	output, _ := p.Run(p.Command, "--list-all")
+	running := make(map[string]bool)
+	for name := range strings.FieldsSeq(output) {
+		running[name] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for name := range p.players {
+		if !running[name] {
+			delete(p.players, name)
+		}
+	}
+}
+
+// parsePlayerctlOutput parses a line produced with playerctlFormat.
+func parsePlayerctlOutput(rawOutput string) NowPlayingData {
+	parts := strings.Split(rawOutput, playerctlSeparator)
+
+	if len(parts) != len(playerctlFields) {
+		log.Printf("Parsing failed, expected %d fields but got %d.", len(playerctlFields), len(parts))
+		return NotPlaying()
+	}
+
+	position, err := parseTime(strings.TrimSpace(parts[3]))
+	if err != nil {
+		log.Printf("Error parsing position: %v", err)
+		position = 0
+	}
+
+	length, err := parseTime(strings.TrimSpace(parts[4]))
+	if err != nil {
+		log.Printf("Error parsing length: %v", err)
+		length = 0
+	}
+
+	// If length is the max integer value, set it to 0 to prevent division errors.
+	if length == 9223372036854775807 {
+		length = 0
+	}
+
+	artists := []Artist{}
+	if parts[1] != "" {
+		artists = []Artist{{Name: parts[1]}}
+	}
+
+	return NowPlayingData{
+		IsPlaying:  parts[0] == "Playing",
+		Status:     playerctlStatus(parts[0]),
+		ProgressMs: position,
+		Item: &Track{
+			Name:       parts[2],
+			Artists:    artists,
+			Album:      parts[5],
+			ArtURL:     parts[6],
+			URL:        parts[7],
+			DurationMs: length,
+		},
+	}
+}
+
+// playerctlStatus maps playerctl's PlaybackStatus onto the Status values.
+func playerctlStatus(status string) string {
+	switch status {
+	case "Playing":
+		return StatusPlaying
+	case "Paused":
+		return StatusPaused
+	default:
+		return StatusStopped
+	}
+}
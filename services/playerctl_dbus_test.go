@@ -0,0 +1,171 @@
+package services
+
+import (
+	"bufio"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"argus/config"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+const (
+	mprisPath   = "/org/mpris/MediaPlayer2"
+	mprisRoot   = "org.mpris.MediaPlayer2"
+	mprisPlayer = "org.mpris.MediaPlayer2.Player"
+)
+
+// startSessionBus starts a private D-Bus session bus for the test and points
+// DBUS_SESSION_BUS_ADDRESS at it, so playerctl only sees the players the test creates.
+func startSessionBus(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading the bus address: %v", err)
+	}
+	address = strings.TrimSpace(address)
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", address)
+	return address
+}
+
+// fakeMPRISPlayer is a media player that implements just enough of MPRIS for playerctl.
+type fakeMPRISPlayer struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+// newFakeMPRISPlayer claims org.mpris.MediaPlayer2.<instance> on the bus at address.
+func newFakeMPRISPlayer(t *testing.T, address, instance string, metadata map[string]dbus.Variant) *fakeMPRISPlayer {
+	t.Helper()
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatalf("connecting to the session bus: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	props, err := prop.Export(conn, mprisPath, prop.Map{
+		mprisRoot: {
+			"Identity": {Value: "Fake Player", Emit: prop.EmitFalse},
+		},
+		mprisPlayer: {
+			"PlaybackStatus": {Value: "Playing", Emit: prop.EmitTrue},
+			"Metadata":       {Value: metadata, Emit: prop.EmitTrue},
+			"Position":       {Value: int64(61_000_000), Emit: prop.EmitFalse},
+			"Rate":           {Value: 1.0, Emit: prop.EmitTrue},
+			"CanControl":     {Value: true, Emit: prop.EmitFalse},
+		},
+	})
+	if err != nil {
+		t.Fatalf("exporting MPRIS properties: %v", err)
+	}
+	node := &introspect.Node{
+		Name: mprisPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{Name: mprisRoot, Properties: props.Introspection(mprisRoot)},
+			{Name: mprisPlayer, Properties: props.Introspection(mprisPlayer)},
+		},
+	}
+	if err := conn.Export(introspect.NewIntrospectable(node), mprisPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := conn.RequestName(mprisRoot+"."+instance, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("claiming the MPRIS name: %v (reply %d)", err, reply)
+	}
+	return &fakeMPRISPlayer{conn: conn, props: props}
+}
+
+// mprisMetadata builds the Metadata property of a track.
+func mprisMetadata(title string, artists ...string) map[string]dbus.Variant {
+	return map[string]dbus.Variant{
+		"mpris:trackid": dbus.MakeVariant(dbus.ObjectPath("/org/mpris/MediaPlayer2/track/1")),
+		"mpris:length":  dbus.MakeVariant(int64(178_000_000)),
+		"xesam:title":   dbus.MakeVariant(title),
+		"xesam:artist":  dbus.MakeVariant(artists),
+		"xesam:album":   dbus.MakeVariant("Album"),
+	}
+}
+
+// TestPlayerctlWatchFollowsMPRISPlayer runs the real playerctl against a fake player on a
+// private session bus, so PropertiesChanged signals go all the way through --follow.
+func TestPlayerctlWatchFollowsMPRISPlayer(t *testing.T) {
+	if _, err := exec.LookPath("playerctl"); err != nil {
+		t.Skip("playerctl is not installed")
+	}
+	address := startSessionBus(t)
+	player := newFakeMPRISPlayer(t, address, "fakeplayer.instance42", mprisMetadata("Stand by Me", "Ben E. King"))
+
+	p := NewPlayerctlProvider(config.Config{PlayerPriority: []string{"fakeplayer"}})
+	updates := make(chan NowPlayingData, 16)
+	stop := make(chan struct{})
+	defer close(stop)
+	go p.Watch(stop, func(data NowPlayingData) { updates <- data })
+
+	waitFor := func(what string, match func(NowPlayingData) bool) NowPlayingData {
+		t.Helper()
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case data := <-updates:
+				if match(data) {
+					return data
+				}
+			case <-timeout:
+				t.Fatalf("watcher never reported %s", what)
+			}
+		}
+	}
+	playing := func(title string) func(NowPlayingData) bool {
+		return func(data NowPlayingData) bool {
+			return data.IsPlaying && data.Item != nil && data.Item.Name == title
+		}
+	}
+
+	data := waitFor("the first track", playing("Stand by Me"))
+	if data.Player != "fakeplayer.instance42" {
+		t.Errorf("Player = %q, want the instance name fakeplayer.instance42", data.Player)
+	}
+
+	player.props.SetMust(mprisPlayer, "Metadata", mprisMetadata("Helplessly Hoping", "Crosby", "Stills", "Nash"))
+	data = waitFor("the track change", playing("Helplessly Hoping"))
+	var artists []string
+	for _, artist := range data.Item.Artists {
+		artists = append(artists, artist.Name)
+	}
+	if want := []string{"Crosby", "Stills", "Nash"}; !slices.Equal(artists, want) {
+		t.Errorf("artists = %q, want %q", artists, want)
+	}
+
+	player.props.SetMust(mprisPlayer, "PlaybackStatus", "Paused")
+	waitFor("the pause", func(data NowPlayingData) bool { return data.Status == StatusPaused })
+
+	// Dropping off the bus makes playerctl print an empty line, and the player is pruned.
+	player.conn.Close()
+	waitFor("the player exiting", func(data NowPlayingData) bool { return data.Status == StatusStopped })
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePlayerctl writes a script that stands in for `playerctl --follow` with one VLC
+// instance running. It prefixes each metadata line with whatever the format asks for,
+// the instance or the bare player name, prints empty lines as they are, and then stays
+// alive like the real process until it is killed.
+func fakePlayerctl(t *testing.T, lines ...string) string {
+	t.Helper()
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\n")
+	script.WriteString("case \"$*\" in *'{{playerInstance}}'*) id=vlc.instance1234 ;; *) id=vlc ;; esac\n")
+	for _, line := range lines {
+		if line == "" {
+			script.WriteString("echo\n")
+			continue
+		}
+		script.WriteString("printf '%s" + playerctlSeparator + "%s\\n' \"$id\" '" + line + "'\n")
+	}
+	script.WriteString("exec sleep 10\n")
+
+	command := filepath.Join(t.TempDir(), "playerctl")
+	if err := os.WriteFile(command, []byte(script.String()), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return command
+}
+
+// playerctlLine builds the playerctlFormat part of a line from the watcher.
+func playerctlLine(status, artist, title string) string {
+	fields := []string{status, artist, title, "61000000", "200000000", "Album", "", ""}
+	return strings.Join(fields, playerctlSeparator)
+}
+
+func TestPlayerctlWatchKeepsRunningInstances(t *testing.T) {
+	const instance = "vlc.instance1234"
+	p := &PlayerctlProvider{
+		Command: fakePlayerctl(t,
+			playerctlLine("Playing", "Ben E. King", "Stand by Me"),
+			// An empty line means some player exited; the one above is still listed.
+			"",
+		),
+		Run: fakeRunner(map[string]string{
+			"--list-all": instance,
+			"--player=" + instance + " metadata": instance + " xesam:artist              Ben E. King\n" +
+				instance + " xesam:title               Stand by Me",
+		}),
+		Priority: []string{"vlc"},
+		players:  make(map[string]playerState),
+	}
+
+	updates := make(chan NowPlayingData, 8)
+	stop := make(chan struct{})
+	defer close(stop)
+	go p.Watch(stop, func(data NowPlayingData) { updates <- data })
+
+	for i := range 2 {
+		select {
+		case data := <-updates:
+			if !data.IsPlaying || data.Player != instance || data.Item.Name != "Stand by Me" {
+				t.Fatalf("update %d = %+v, want %s playing Stand by Me", i, data, instance)
+			}
+			if len(data.Item.Artists) != 1 || data.Item.Artists[0].Name != "Ben E. King" {
+				t.Errorf("update %d artists = %+v, want Ben E. King", i, data.Item.Artists)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no update %d from the watcher", i)
+		}
+	}
+}
+
+// fakeRunner answers commands from canned output keyed by their arguments. Anything
+// else fails, like playerctl does for a player that went away.
+func fakeRunner(outputs map[string]string) Runner {
+	return func(tool string, args ...string) (string, error) {
+		output, ok := outputs[strings.Join(args, " ")]
+		if !ok {
+			return "", fmt.Errorf("%s %v: exit status 1", tool, args)
+		}
+		return output, nil
+	}
+}
+
+// metadataArgs are the arguments Current passes to read one player's metadata.
+func metadataArgs(player string) string {
+	return "--player=" + player + " metadata --format " + playerctlFormat
+}
+
+func TestPlayerctlCurrent(t *testing.T) {
+	spotify := playerctlLine("Playing", "Ben E. King", "Stand by Me")
+	firefox := playerctlLine("Playing", "Rick Astley", "Never Gonna Give You Up")
+	vlcPaused := playerctlLine("Paused", "Crosby, Stills & Nash", "Helplessly Hoping")
+
+	tests := []struct {
+		name      string
+		outputs   map[string]string
+		priority  []string
+		anyPlayer bool
+		want      string
+		status    string
+	}{
+		{
+			name: "priority order",
+			outputs: map[string]string{
+				"--list-all":                       "firefox.instance42\nspotify",
+				metadataArgs("spotify"):            spotify,
+				metadataArgs("firefox.instance42"): firefox,
+			},
+			priority: []string{"spotify", "firefox*"},
+			want:     "spotify",
+			status:   StatusPlaying,
+		},
+		{
+			name: "playing preferred over paused",
+			outputs: map[string]string{
+				"--list-all":                  "vlc.instance7\nspotify",
+				metadataArgs("vlc.instance7"): vlcPaused,
+				metadataArgs("spotify"):       spotify,
+			},
+			priority: []string{"vlc", "spotify"},
+			want:     "spotify",
+			status:   StatusPlaying,
+		},
+		{
+			name: "paused when nothing plays",
+			outputs: map[string]string{
+				"--list-all":                  "vlc.instance7",
+				metadataArgs("vlc.instance7"): vlcPaused,
+			},
+			priority: []string{"spotify", "vlc"},
+			want:     "vlc.instance7",
+			status:   StatusPaused,
+		},
+		{
+			name: "unlisted player needs fallback",
+			outputs: map[string]string{
+				"--list-all":                       "firefox.instance42",
+				metadataArgs("firefox.instance42"): firefox,
+			},
+			priority: []string{"spotify"},
+			want:     "",
+			status:   StatusStopped,
+		},
+		{
+			name: "any player fallback",
+			outputs: map[string]string{
+				"--list-all":                       "firefox.instance42",
+				metadataArgs("firefox.instance42"): firefox,
+			},
+			priority:  []string{"spotify"},
+			anyPlayer: true,
+			want:      "firefox.instance42",
+			status:    StatusPlaying,
+		},
+		{
+			name:     "list-all fails",
+			outputs:  map[string]string{},
+			priority: []string{"spotify"},
+			want:     "",
+			status:   StatusStopped,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &PlayerctlProvider{
+				Command:   "playerctl",
+				Run:       fakeRunner(tt.outputs),
+				Priority:  tt.priority,
+				AnyPlayer: tt.anyPlayer,
+				players:   make(map[string]playerState),
+			}
+			data, err := p.Current()
+			if err != nil {
+				t.Fatalf("Current() error = %v", err)
+			}
+			if data.Player != tt.want || data.Status != tt.status {
+				t.Errorf("Current() = player %q status %q, want %q %q", data.Player, data.Status, tt.want, tt.status)
+			}
+		})
+	}
+}
+
+func TestPlayerctlCurrentReadsEveryArtist(t *testing.T) {
+	// The --format output joins the artists, so it can't tell them from a name with a comma.
+	line := playerctlLine("Playing", "Crosby, Stills, Nash", "Helplessly Hoping")
+	table := "vlc xesam:album               Crosby, Stills & Nash\n" +
+		"vlc xesam:artist              Crosby\n" +
+		"vlc xesam:artist              Stills\n" +
+		"vlc xesam:artist              Nash\n" +
+		"vlc xesam:title               Helplessly Hoping\n"
+
+	tests := []struct {
+		name    string
+		outputs map[string]string
+		want    []string
+	}{
+		{
+			name: "one row per artist",
+			outputs: map[string]string{
+				"--list-all":            "vlc",
+				metadataArgs("vlc"):     line,
+				"--player=vlc metadata": table,
+			},
+			want: []string{"Crosby", "Stills", "Nash"},
+		},
+		{
+			name: "table query fails",
+			outputs: map[string]string{
+				"--list-all":        "vlc",
+				metadataArgs("vlc"): line,
+			},
+			want: []string{"Crosby, Stills, Nash"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &PlayerctlProvider{
+				Command:  "playerctl",
+				Run:      fakeRunner(tt.outputs),
+				Priority: []string{"vlc"},
+				players:  make(map[string]playerState),
+			}
+			data, err := p.Current()
+			if err != nil {
+				t.Fatalf("Current() error = %v", err)
+			}
+			var artists []string
+			for _, artist := range data.Item.Artists {
+				artists = append(artists, artist.Name)
+			}
+			if !slices.Equal(artists, tt.want) {
+				t.Errorf("Current() artists = %q, want %q", artists, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzParsePlayerctlOutput(f *testing.F) {
+	f.Add("Playing", "Crosby; Stills", "Helplessly Hoping; Live")
+	f.Add("Paused", "Ben E. King", "Stand by Me")
+	f.Fuzz(func(t *testing.T, status, artist, title string) {
+		data := parsePlayerctlOutput(playerctlLine(status, artist, title))
+		if strings.Contains(status+artist+title, playerctlSeparator) {
+			return
+		}
+		if data.Item == nil || data.Item.Name != title {
+			t.Errorf("title = %+v, want %q", data.Item, title)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Poller tracks the now playing info and shares it with every consumer, so only one
+// player query runs no matter how many overlays are open. When the provider is a
+// Watcher it follows its updates and only uses the clock for progress ticks; otherwise
+// it polls the provider every interval.
+type Poller struct {
+	service  *NowPlayingService
+	watcher  Watcher
+	interval time.Duration
+
+	// OnUpdate, when set, is called after every update that found something worth reporting:
+	// changed is true when the track or play state changed, and false for a progress tick
+	// while the same track keeps playing.
+	OnUpdate func(data NowPlayingData, changed bool)
+
+	updateMu sync.Mutex
+	mu       sync.RWMutex
+	current  NowPlayingData
+	lastErr  string
+
+	// watched is the last snapshot the watcher pushed and when it arrived.
+	watchedMu sync.Mutex
+	watched   NowPlayingData
+	watchedAt time.Time
+}
+
+// NewPoller creates a poller that reports progress every interval.
+func NewPoller(service *NowPlayingService, interval time.Duration) *Poller {
+	p := &Poller{service: service, interval: interval, current: NotPlaying(), watched: NotPlaying()}
+	if watcher, ok := service.Provider().(Watcher); ok {
+		p.watcher = watcher
+	}
+	return p
+}
+
+// Current returns the most recent data.
+func (p *Poller) Current() NowPlayingData {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.current
+}
+
+// Run tracks the player until stop is closed.
+func (p *Poller) Run(stop <-chan struct{}) {
+	if p.watcher != nil {
+		go p.watcher.Watch(stop, func(data NowPlayingData) {
+			p.watchedMu.Lock()
+			p.watched, p.watchedAt = data, time.Now()
+			p.watchedMu.Unlock()
+			p.update()
+		})
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.update()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// update reads the latest data and reports it.
+func (p *Poller) update() {
+	p.updateMu.Lock()
+	defer p.updateMu.Unlock()
+
+	data := stamp(p.read(), time.Now())
+
+	p.mu.Lock()
+	previous := p.current
+	p.current = data
+	p.mu.Unlock()
+
+	if p.OnUpdate != nil {
+		if changed := !SameTrack(previous, data); changed || data.IsPlaying {
+			p.OnUpdate(data, changed)
+		}
+	}
+}
+
+func (p *Poller) read() NowPlayingData {
+	if p.watcher != nil {
+		return p.interpolated()
+	}
+
+	data, err := p.service.GetNowPlayingInfo()
+	if err != nil {
+		// Only log when the error changes so a missing player doesn't flood the log every second.
+		if err.Error() != p.lastErr {
+			log.Printf("Error getting now playing info: %v", err)
+			p.lastErr = err.Error()
+		}
+		return NotPlaying()
+	}
+	p.lastErr = ""
+	return data
+}
+
+// interpolated returns the last watched snapshot with its progress advanced to now.
+func (p *Poller) interpolated() NowPlayingData {
+	p.watchedMu.Lock()
+	defer p.watchedMu.Unlock()
+
+	data := p.watched
+	if data.IsPlaying && !p.watchedAt.IsZero() {
+		rate := data.PlaybackRate
+		if rate == 0 {
+			rate = 1
+		}
+		data.ProgressMs += int64(float64(time.Since(p.watchedAt).Milliseconds()) * rate)
+		if data.Item != nil && data.Item.DurationMs > 0 {
+			data.ProgressMs = min(data.ProgressMs, data.Item.DurationMs)
+		}
+	}
+	return data
+}
+
+// stamp records when data was read and fills in the playback rate for providers that
+// don't report one.
+func stamp(data NowPlayingData, at time.Time) NowPlayingData {
+	data.Timestamp = at.UnixMilli()
+	if !data.IsPlaying {
+		data.PlaybackRate = 0
+	} else if data.PlaybackRate == 0 {
+		data.PlaybackRate = 1
+	}
+	return data
+}
+
+// SameTrack reports whether a and b describe the same track in the same play state.
+func SameTrack(a, b NowPlayingData) bool {
+	if a.IsPlaying != b.IsPlaying || a.Status != b.Status || (a.Item == nil) != (b.Item == nil) {
+		return false
+	}
+	if a.Item == nil {
+		return true
+	}
+	return a.Item.Name == b.Item.Name && a.Item.Album == b.Item.Album && slices.Equal(a.Item.Artists, b.Item.Artists)
+}
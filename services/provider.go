@@ -0,0 +1,73 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+
+	"argus/config"
+	"argus/music"
+)
+
+// Provider is a source of now playing information, such as a media player CLI.
+type Provider interface {
+	// Name identifies the provider in config and logs.
+	Name() string
+	// Available returns an error explaining why the provider can't run on this machine.
+	Available() error
+	// Current returns what is playing right now.
+	Current() (NowPlayingData, error)
+}
+
+// Watcher is implemented by providers that can push updates instead of being polled.
+type Watcher interface {
+	// Watch calls update with a fresh snapshot whenever the player changes, until stop is closed.
+	Watch(stop <-chan struct{}, update func(NowPlayingData))
+}
+
+// Runner runs a command to completion and returns its output without the trailing newline. Providers take
+// one so tests can swap in canned output.
+type Runner func(tool string, args ...string) (string, error)
+
+// defaultRunner runs real commands.
+var defaultRunner Runner = music.GetNowPlayingInfo
+
+// ProviderFactory creates a provider from the application config.
+type ProviderFactory func(cfg config.Config) Provider
+
+type registeredProvider struct {
+	name    string
+	factory ProviderFactory
+	// manual providers are only used when picked with NOW_PLAYING_PROVIDER, because
+	// being available says nothing about whether they have anything to play.
+	manual bool
+}
+
+// providers are tried in registration order when no provider is configured.
+var providers = []registeredProvider{
+	{name: "playerctl", factory: func(cfg config.Config) Provider { return NewPlayerctlProvider(cfg) }},
+	{name: "nowplaying-cli", factory: func(cfg config.Config) Provider { return NewNowPlayingCLIProvider(cfg) }},
+	{name: "mpd", factory: func(cfg config.Config) Provider { return NewMPDProvider(cfg) }, manual: true},
+}
+
+// Register adds a provider that can be picked with NOW_PLAYING_PROVIDER.
+func Register(name string, factory ProviderFactory) {
+	providers = append(providers, registeredProvider{name: name, factory: factory})
+}
+
+// ProviderNames lists the registered providers in the order they are tried.
+func ProviderNames() []string {
+	names := make([]string, len(providers))
+	for i, p := range providers {
+		names[i] = p.name
+	}
+	return names
+}
+
+// NewProvider creates the registered provider with the given name.
+func NewProvider(name string, cfg config.Config) (Provider, error) {
+	i := slices.IndexFunc(providers, func(p registeredProvider) bool { return p.name == name })
+	if i < 0 {
+		return nil, fmt.Errorf("unknown now playing provider %q, expected one of %v", name, ProviderNames())
+	}
+	return providers[i].factory(cfg), nil
+}
@@ -1,27 +1,58 @@
 package services
 
 import (
-	"argus/dependencies"
-	"argus/music"
 	"fmt"
 	"log"
-	"runtime"
 	"strconv"
 	"strings"
+
+	"argus/config"
+)
+
+// Playback states reported in NowPlayingData.Status.
+const (
+	StatusPlaying = "playing"
+	StatusPaused  = "paused"
+	StatusStopped = "stopped"
 )
 
 // NowPlayingData represents the data to be returned by the API.
 type NowPlayingData struct {
 	IsPlaying  bool   `json:"is_playing"`
+	Status     string `json:"status"`
 	ProgressMs int64  `json:"progress_ms,omitempty"`
 	Item       *Track `json:"item,omitempty"`
+	// Player names the player the data came from, when the provider can tell them apart.
+	Player string `json:"player,omitempty"`
+	// Timestamp is when ProgressMs was read, in Unix milliseconds.
+	Timestamp int64 `json:"timestamp,omitempty"`
+	// PlaybackRate is how fast progress advances, 1 for normal playback and 0 when paused.
+	// Overlays can use it with Timestamp to move the progress bar between updates.
+	PlaybackRate float64 `json:"playback_rate"`
 }
 
 // Track represents the now playing song information.
 type Track struct {
 	Name       string   `json:"name"`
 	Artists    []Artist `json:"artists"`
+	Album      string   `json:"album,omitempty"`
+	ArtURL     string   `json:"art_url,omitempty"`
+	URL        string   `json:"url,omitempty"`
 	DurationMs int64    `json:"duration_ms,omitempty"`
+	// ArtData holds the cover itself for players that hand it over directly instead of
+	// by URL. It is served from /art/current rather than in the JSON.
+	ArtData []byte `json:"-"`
+	// ArtID identifies ArtData, so overlays can tell when the cover changes.
+	ArtID string `json:"art_id,omitempty"`
+}
+
+// ArtistNames joins the track's artists for display.
+func (t *Track) ArtistNames() string {
+	names := make([]string, len(t.Artists))
+	for i, artist := range t.Artists {
+		names[i] = artist.Name
+	}
+	return strings.Join(names, ", ")
 }
 
 // Artist represents the artist's name.
@@ -29,113 +60,61 @@ type Artist struct {
 	Name string `json:"name"`
 }
 
-// NowPlayingService contains the logic for the now playing feature.
-type NowPlayingService struct{}
-
-// NewNowPlayingService creates a new instance of the service.
-func NewNowPlayingService() *NowPlayingService {
-	return &NowPlayingService{}
+// NotPlaying is the data reported when nothing is playing.
+func NotPlaying() NowPlayingData {
+	return NowPlayingData{IsPlaying: false, Status: StatusStopped}
 }
 
-// GetNowPlayingInfo retrieves the current track information.
-func (s *NowPlayingService) GetNowPlayingInfo() (NowPlayingData, error) {
-	dep := dependencies.GetNowPlayingDependency()
-	if dep == nil {
-		return NowPlayingData{}, fmt.Errorf("unsupported OS: %s", runtime.GOOS)
-	}
-
-	ok, errMessage := dep.Check()
-	if !ok {
-		return NowPlayingData{}, fmt.Errorf("dependency check failed: %s", errMessage)
-	}
-
-	var rawOutput string
-	var err error
-	var playerFound bool
-
-	if runtime.GOOS == "linux" {
-		playerPriority := []string{"spotify", "vlc"}
-		for _, player := range playerPriority {
-			args := []string{"--player=" + player, "metadata", "--format", "{{status}};{{artist}};{{title}};{{position}};{{mpris:length}}"}
-			output, innerErr := music.GetNowPlayingInfo(dep.Command, args...)
+// NowPlayingService contains the logic for the now playing feature.
+type NowPlayingService struct {
+	provider Provider
+	// unavailable explains why no provider could be picked.
+	unavailable error
+}
 
-			if innerErr == nil && strings.HasPrefix(output, "Playing") {
-				rawOutput = output
-				playerFound = true
-				break
-			}
-		}
-	} else if runtime.GOOS == "darwin" {
-		args := []string{"current"}
-		rawOutput, err = music.GetNowPlayingInfo(dep.Command, args...)
+// NewNowPlayingService creates a service backed by the provider named in the config, or
+// by the first available provider when none is configured.
+func NewNowPlayingService(cfg config.Config) (*NowPlayingService, error) {
+	if cfg.NowPlayingProvider != "" {
+		provider, err := NewProvider(cfg.NowPlayingProvider, cfg)
 		if err != nil {
-			return NowPlayingData{}, err
+			return nil, err
 		}
-		playerFound = true
-	}
-
-	if !playerFound {
-		return NowPlayingData{IsPlaying: false}, nil
+		return &NowPlayingService{provider: provider, unavailable: provider.Available()}, nil
 	}
 
-	if rawOutput == "" {
-		return NowPlayingData{IsPlaying: false}, nil
-	}
-
-	if runtime.GOOS == "linux" {
-		parts := strings.Split(rawOutput, ";")
-
-		if len(parts) < 5 {
-			log.Println("Parsing failed, not enough parts.")
-			return NowPlayingData{IsPlaying: false}, nil
-		}
-
-		position, err := parseTime(parts[3])
-		if err != nil {
-			log.Printf("Error parsing position: %v", err)
-			position = 0
+	var reasons []string
+	for _, registered := range providers {
+		if registered.manual {
+			continue
 		}
-
-		length, err := parseTime(parts[4])
-		if err != nil {
-			log.Printf("Error parsing length: %v", err)
-			length = 0
-		}
-
-		// If length is the max integer value, set it to 0 to prevent division errors.
-		if length == 9223372036854775807 {
-			length = 0
+		provider := registered.factory(cfg)
+		err := provider.Available()
+		if err == nil {
+			if cfg.ShowLogs {
+				log.Printf("Using now playing provider %s", provider.Name())
+			}
+			return &NowPlayingService{provider: provider}, nil
 		}
-
-		return NowPlayingData{
-			IsPlaying:  true,
-			ProgressMs: position,
-			Item: &Track{
-				Name:       parts[2],
-				Artists:    []Artist{{Name: parts[1]}},
-				DurationMs: length,
-			},
-		}, nil
+		reasons = append(reasons, err.Error())
 	}
+	return &NowPlayingService{unavailable: fmt.Errorf("no now playing provider available: %s", strings.Join(reasons, "; "))}, nil
+}
 
-	if runtime.GOOS == "darwin" {
-		parts := strings.SplitN(rawOutput, " by ", 2)
-
-		if len(parts) < 2 {
-			log.Println("Parsing failed, not enough parts.")
-			return NowPlayingData{IsPlaying: false}, nil
-		}
-
-		return NowPlayingData{
-			IsPlaying: true,
-			Item: &Track{
-				Name:    parts[0],
-				Artists: []Artist{{Name: parts[1]}},
-			},
-		}, nil
+// Provider returns the provider the service reads from, or nil if none is available.
+func (s *NowPlayingService) Provider() Provider {
+	if s.unavailable != nil {
+		return nil
 	}
+	return s.provider
+}
 
-	return NowPlayingData{IsPlaying: false}, nil
+// GetNowPlayingInfo retrieves the current track information.
+func (s *NowPlayingService) GetNowPlayingInfo() (NowPlayingData, error) {
+	if s.unavailable != nil {
+		return NowPlayingData{}, s.unavailable
+	}
+	return s.provider.Current()
 }
 
 // parseTime converts a string of microseconds to milliseconds.
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Overlays call artists.map(), so a track without artists must still send a list.
+func TestTrackWithoutArtistsHasEmptyList(t *testing.T) {
+	tests := map[string]NowPlayingData{
+		"playerctl":      parsePlayerctlOutput(playerctlLine("Playing", "", "Some Browser Tab")),
+		"nowplaying-cli": parseNowPlayingCLIOutput("Some Browser Tab\nnull\nnull\n60\n1\n1\nnull"),
+		"mpd":            parseMPD([][2]string{{"state", "play"}}, [][2]string{{"file", "untagged.flac"}}),
+	}
+	for name, data := range tests {
+		out, err := json.Marshal(data.Item)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !strings.Contains(string(out), `"artists":[]`) {
+			t.Errorf("%s: track JSON = %s, want an empty artists list", name, out)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package terminal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"argus/colors"
+)
+
+// Sender sends a message to a chat channel.
+type Sender interface {
+	Say(channel, text string) error
+}
+
+// StartInput reads lines typed into the terminal and sends each one to channel. When
+// stdin is a terminal, it shows an input prompt below the scrolling output and calls quit
+// on Ctrl+C or Ctrl+D. The returned function restores the terminal and must be called
+// before exiting.
+func StartInput(sender Sender, channel string, quit func()) (restore func()) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		go readLines(sender, channel)
+		return func() {}
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		log.Printf("Chat input disabled, can't take over the terminal: %v", err)
+		return func() {}
+	}
+
+	// Everything has to be written through the line editor so the prompt stays intact.
+	editor := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, channel+"> ")
+	output.set(editor)
+	log.SetOutput(editor)
+
+	go func() {
+		for {
+			line, err := editor.ReadLine()
+			if err != nil {
+				quit()
+				return
+			}
+			say(sender, channel, line)
+		}
+	}()
+
+	return func() {
+		output.set(os.Stdout)
+		log.SetOutput(os.Stderr)
+		term.Restore(fd, state)
+	}
+}
+
+// readLines sends lines piped into stdin.
+func readLines(sender Sender, channel string) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		say(sender, channel, scanner.Text())
+	}
+}
+
+func say(sender Sender, channel, line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if err := sender.Say(channel, line); err != nil {
+		fmt.Fprintf(output, "%s [ERROR] Message not sent: %v%s\n", colors.ColorRed, err, colors.ColorReset)
+	}
+}
@@ -0,0 +1,255 @@
+package terminal
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+
+	"argus/bus"
+	"argus/colors"
+	"argus/config"
+)
+
+// A regular expression to strip ANSI codes.
+var ansiStripRegex = regexp.MustCompile("[\u001B\u009B][[\\]()#;?]*(?:(?:(?:[a-zA-Z\\d]*(?:;[a-zA-Z\\d]*)*)?(?:[a-zA-Z\\d]+(?:;[a-zA-Z\\d]*)*)?[a-zA-Z])|(?:[a-zA-Z\\d]*(?:;[a-zA-Z\\d]*)*)?(?:[a-zA-Z\\d]+(?:;[a-zA-Z\\d]*)*)?[a-zA-Z\u0080-\u009F])")
+
+// output is where everything is printed. It is swapped for the line editor when
+// StartInput takes over the terminal.
+var output = &lockedWriter{w: os.Stdout}
+
+// lockedWriter lets the writer be swapped while events are being printed.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+func (l *lockedWriter) set(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w = w
+}
+
+// Run prints every event published on the bus until the bus subscription is closed.
+func Run(b *bus.Bus, cfg config.Config) {
+	events, _ := b.Subscribe(256)
+
+	fmt.Fprintln(output, "\n-------------------- Twitch Chat --------------------")
+	for event := range events {
+		render(event, cfg)
+	}
+}
+
+func render(event bus.Event, cfg config.Config) {
+	tag := channelTag(cfg, event)
+	switch e := event.(type) {
+	case bus.ChatMessage:
+		printChat(tag, e)
+	case bus.Follow:
+		printActivity(tag, colors.ColorGreen, fmt.Sprintf("%s is now following!", e.User))
+	case bus.Raid:
+		printActivity(tag, colors.ColorOrange, fmt.Sprintf("%s is raiding with %d viewers!", e.From, e.Viewers))
+	case bus.Subscribe:
+		switch {
+		case e.Months > 0:
+			text := fmt.Sprintf("%s resubscribed for %d months!", e.User, e.Months)
+			if e.Message != "" {
+				text += " " + e.Message
+			}
+			printActivity(tag, colors.ColorWhite, text)
+		case e.Gift:
+			printActivity(tag, colors.ColorWhite, fmt.Sprintf("%s received a gifted %s sub!", e.User, e.Tier))
+		default:
+			printActivity(tag, colors.ColorWhite, fmt.Sprintf("New Subscriber: %s!", e.User))
+		}
+	case bus.GiftSub:
+		printActivity(tag, colors.ColorPink, fmt.Sprintf("%s gifted %d %s subs!", e.User, e.Total, e.Tier))
+	case bus.Cheer:
+		printActivity(tag, colors.ColorPurple, fmt.Sprintf("%s cheered %d bits!", e.User, e.Bits))
+	case bus.Redemption:
+		printActivity(tag, colors.ColorCyan, fmt.Sprintf("%s redeemed %d channel points for: %s", e.User, e.Cost, e.Reward))
+	case bus.Activity:
+		printActivity(tag, activityColor(e.Type), e.Text)
+	case bus.TrackChanged:
+		if e.Data.IsPlaying && e.Data.Item != nil {
+			source := ""
+			if e.Data.Player != "" {
+				source = " (" + e.Data.Player + ")"
+			}
+			fmt.Fprintf(output, "%s [MUSIC] Now playing: %s - %s%s%s\n", colors.ColorGray, e.Data.Item.Name, e.Data.Item.ArtistNames(), source, colors.ColorReset)
+		}
+	case bus.UserNotice:
+		printUserNotice(tag, e)
+	case bus.ClearChat:
+		switch {
+		case e.User == "":
+			printNotice(tag, "MOD", colors.ColorRed, "Chat was cleared")
+		case e.Duration > 0:
+			printNotice(tag, "MOD", colors.ColorRed, fmt.Sprintf("%s was timed out for %s", e.User, e.Duration))
+		default:
+			printNotice(tag, "MOD", colors.ColorRed, fmt.Sprintf("%s was banned", e.User))
+		}
+	case bus.ClearMessage:
+		printNotice(tag, "MOD", colors.ColorRed, fmt.Sprintf("A message from %s was deleted: %s", e.User, e.Text))
+	case bus.RoomState:
+		printNotice(tag, "ROOM", colors.ColorYellow, strings.Join(e.Changes, ", "))
+	case bus.Notice:
+		printNotice(tag, "NOTICE", colors.ColorCyan, e.Text)
+	case bus.ConnectionState:
+		if cfg.ShowLogs {
+			fmt.Fprintf(output, "%s [%s] %s%s\n", colors.ColorGray, strings.ToUpper(e.Service), e.State, colors.ColorReset)
+		}
+	}
+}
+
+func printChat(tag string, msg bus.ChatMessage) {
+	color := getColorByRole(msg.Badges)
+	if msg.Tags == nil {
+		// Fallback for messages without tags
+		color = colors.ColorTwitchPurple
+	}
+
+	// Get the terminal width and wrap the message
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err == nil && width > 0 {
+		prefix := fmt.Sprintf("%s [CHAT] %s[%s]%s: ", tag, color, msg.DisplayName, colors.ColorReset)
+		prefixLen := len(stripAnsiCodes(prefix))
+		wrappedMessage := wrapMessage(msg.Text, width-prefixLen, prefixLen)
+		fmt.Fprintf(output, "%s\n", prefix+wrappedMessage)
+	} else {
+		fmt.Fprintf(output, "%s [CHAT] %s[%s]%s: %s\n", tag, color, msg.DisplayName, colors.ColorReset, msg.Text)
+	}
+}
+
+func printActivity(tag, color, text string) {
+	fmt.Fprintf(output, "%s%s [ACTIVITY] %s%s\n", tag, color, text, colors.ColorReset)
+}
+
+func printNotice(tag, label, color, text string) {
+	fmt.Fprintf(output, "%s%s [%s] %s%s\n", tag, color, label, text, colors.ColorReset)
+}
+
+// printUserNotice renders subs, raids and announcements that Twitch posts in chat.
+func printUserNotice(tag string, notice bus.UserNotice) {
+	text := notice.SystemMessage
+	if text == "" {
+		text = notice.User
+	}
+	if notice.Text != "" {
+		text += " " + notice.Text
+	}
+
+	switch notice.Type {
+	case "sub", "resub":
+		printNotice(tag, "SUB", colors.ColorWhite, text)
+	case "subgift", "submysterygift":
+		printNotice(tag, "SUB", colors.ColorPink, text)
+	case "raid":
+		printNotice(tag, "RAID", colors.ColorOrange, text)
+	case "announcement":
+		printNotice(tag, "ANNOUNCEMENT", announcementColor(notice.Color), fmt.Sprintf("%s: %s", notice.User, notice.Text))
+	default:
+		printNotice(tag, "USERNOTICE", colors.ColorGray, text)
+	}
+}
+
+// channelColors are used to tell channels apart when more than one is joined.
+var channelColors = []string{colors.ColorCyan, colors.ColorGreen, colors.ColorYellow, colors.ColorBlue, colors.ColorOrange, colors.ColorPink, colors.ColorPurple}
+
+// channelTag returns the colored channel name printed in front of an event's line when
+// more than one channel is joined, or "" otherwise.
+func channelTag(cfg config.Config, event bus.Event) string {
+	if len(cfg.Channels) < 2 {
+		return ""
+	}
+
+	channel := bus.ChannelOf(event)
+	if channel == "" {
+		return ""
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(channel))
+	color := channelColors[hash.Sum32()%uint32(len(channelColors))]
+	return fmt.Sprintf("%s[%s]%s", color, strings.TrimPrefix(channel, "#"), colors.ColorReset)
+}
+
+// announcementColor maps the color a moderator picked for an announcement to the terminal.
+func announcementColor(color string) string {
+	switch color {
+	case "BLUE":
+		return colors.ColorBlue
+	case "GREEN":
+		return colors.ColorGreen
+	case "ORANGE":
+		return colors.ColorOrange
+	case "PURPLE":
+		return colors.ColorPurple
+	default:
+		return colors.ColorTwitchPurple
+	}
+}
+
+// activityColor picks a color for the EventSub types that are published as generic activity.
+func activityColor(eventType string) string {
+	switch {
+	case strings.HasPrefix(eventType, "channel.hype_train."):
+		return colors.ColorYellow
+	case strings.HasPrefix(eventType, "channel.poll."):
+		return colors.ColorBlue
+	case strings.HasPrefix(eventType, "channel.prediction."):
+		return colors.ColorTeal
+	case strings.HasPrefix(eventType, "channel.ad_break."):
+		return colors.ColorRed
+	case strings.HasPrefix(eventType, "channel.shoutout."):
+		return colors.ColorLime
+	default:
+		return colors.ColorGray
+	}
+}
+
+func wrapMessage(message string, width int, prefixLen int) string {
+	var builder strings.Builder
+	words := strings.Fields(message)
+	if len(words) == 0 {
+		return ""
+	}
+
+	currentLineLen := 0
+	for i, word := range words {
+		if currentLineLen+len(word)+1 > width {
+			builder.WriteString("\n" + strings.Repeat(" ", prefixLen))
+			currentLineLen = 0
+		}
+		builder.WriteString(word)
+		if i < len(words)-1 {
+			builder.WriteString(" ")
+			currentLineLen += len(word) + 1
+		} else {
+			currentLineLen += len(word)
+		}
+	}
+	return builder.String()
+}
+
+func stripAnsiCodes(str string) string {
+	return ansiStripRegex.ReplaceAllString(str, "")
+}
+
+func getColorByRole(badges string) string {
+	if strings.Contains(badges, "moderator") || strings.Contains(badges, "broadcaster") {
+		return colors.ColorRed
+	}
+	return colors.ColorTwitchPurple
+}
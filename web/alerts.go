@@ -0,0 +1,144 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"argus/bus"
+	"argus/config"
+)
+
+// Alert is what the alert overlay receives for each piece of channel activity.
+type Alert struct {
+	Type       string `json:"type"`
+	Text       string `json:"text"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// defaultAlerts are used for every alert type the config doesn't override. Templates
+// are executed against the matching bus event, so any of its fields can be used.
+var defaultAlerts = map[string]config.AlertSettings{
+	"follow":     {Duration: 4 * time.Second, Template: "{{.User}} just followed!"},
+	"raid":       {Duration: 8 * time.Second, Template: "{{.From}} is raiding with {{.Viewers}} viewers!"},
+	"subscribe":  {Duration: 6 * time.Second, Template: "{{if .Months}}{{.User}} resubscribed for {{.Months}} months!{{else}}{{.User}} just subscribed!{{end}}"},
+	"gift_sub":   {Duration: 6 * time.Second, Template: "{{.User}} gifted {{.Total}} {{.Tier}} subs!"},
+	"cheer":      {Duration: 5 * time.Second, Template: "{{.User}} cheered {{.Bits}} bits!"},
+	"redemption": {Duration: 5 * time.Second, Template: "{{.User}} redeemed {{.Reward}}"},
+}
+
+// alertStyle is the parsed form of an alert type's settings.
+type alertStyle struct {
+	duration time.Duration
+	template *template.Template
+}
+
+// newAlertStyles merges the configured alert settings over the defaults and parses the templates.
+func newAlertStyles(cfg config.Config) (map[string]alertStyle, error) {
+	for kind := range cfg.Alerts {
+		if _, ok := defaultAlerts[kind]; !ok {
+			return nil, fmt.Errorf("unknown alert type %q", kind)
+		}
+	}
+
+	styles := make(map[string]alertStyle, len(defaultAlerts))
+	for kind, settings := range defaultAlerts {
+		override := cfg.Alerts[kind]
+		if override.Duration > 0 {
+			settings.Duration = override.Duration
+		}
+		if override.Template != "" {
+			settings.Template = override.Template
+		}
+
+		tmpl, err := template.New(kind).Parse(settings.Template)
+		if err != nil {
+			return nil, fmt.Errorf("alert template for %s: %w", kind, err)
+		}
+		styles[kind] = alertStyle{duration: settings.Duration, template: tmpl}
+	}
+	return styles, nil
+}
+
+// toAlert turns a bus event into an overlay alert, if it is a type that gets one.
+func toAlert(styles map[string]alertStyle, event bus.Event) (Alert, bool) {
+	style, ok := styles[event.Kind()]
+	if !ok {
+		return Alert{}, false
+	}
+
+	var text strings.Builder
+	if err := style.template.Execute(&text, event); err != nil {
+		return Alert{}, false
+	}
+	return Alert{Type: event.Kind(), Text: text.String(), DurationMs: style.duration.Milliseconds()}, true
+}
+
+func alertsHandler(w http.ResponseWriter, r *http.Request) {
+	html := `
+		<!DOCTYPE html>
+		<html>
+		<head>
+			<title>Alerts</title>
+			<script src="https://cdn.jsdelivr.net/npm/@tailwindcss/browser@4"></script>
+			<style>
+				.alert {
+					opacity: 0;
+					transform: translateY(-2rem) scale(0.95);
+					transition: opacity 0.4s ease, transform 0.4s ease;
+				}
+				.alert.show {
+					opacity: 1;
+					transform: translateY(0) scale(1);
+				}
+				.alert-follow { border-color: #22c55e; }
+				.alert-raid { border-color: #f97316; }
+				.alert-subscribe { border-color: #ffffff; }
+				.alert-gift_sub { border-color: #f472b6; }
+				.alert-cheer { border-color: #9146ff; }
+				.alert-redemption { border-color: #06b6d4; }
+			</style>
+		</head>
+		<body class="bg-transparent text-white flex items-start justify-center min-h-screen pt-8">
+			<div id="alert" class="alert p-6 rounded-xl shadow-lg border-4 text-3xl font-bold text-center max-w-2xl" style="background-color: rgba(31, 41, 55, 0.85);"></div>
+			<script>
+				const alertEl = document.getElementById('alert');
+				const queue = [];
+				let playing = false;
+
+				// Alerts are played one at a time, each for its own duration.
+				function playNext() {
+					if (playing || queue.length === 0) {
+						return;
+					}
+					playing = true;
+					const alert = queue.shift();
+
+					alertEl.className = 'alert p-6 rounded-xl shadow-lg border-4 text-3xl font-bold text-center max-w-2xl alert-' + alert.type;
+					alertEl.textContent = alert.text;
+					requestAnimationFrame(() => alertEl.classList.add('show'));
+
+					setTimeout(() => {
+						alertEl.classList.remove('show');
+						// Wait for the fade out before starting the next alert.
+						setTimeout(() => {
+							playing = false;
+							playNext();
+						}, 500);
+					}, alert.duration_ms);
+				}
+
+				const source = new EventSource('/alerts/events');
+				source.onmessage = (event) => {
+					queue.push(JSON.parse(event.data));
+					playNext();
+				};
+			</script>
+		</body>
+		</html>
+	`
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprint(w, html)
+}
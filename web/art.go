@@ -0,0 +1,249 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	// Register the decoders for the formats players commonly hand out.
+	_ "image/gif"
+	_ "image/png"
+
+	"argus/config"
+	"argus/services"
+)
+
+const (
+	// maxArtBytes caps how much is read for a single cover.
+	maxArtBytes = 10 << 20
+	// maxCachedArt is how many covers are kept on disk.
+	maxCachedArt = 200
+)
+
+// errNoArt is returned when the current track has no usable cover.
+var errNoArt = errors.New("no album art available")
+
+// artCache fetches covers from wherever the player says they are, resizes them and keeps
+// them on disk so OBS can load them from Argus instead of a file:// path or expiring URL.
+type artCache struct {
+	dir         string
+	size        int
+	placeholder string
+	client      *http.Client
+
+	// mu serializes fetches so several overlays asking at once only fetch a cover once.
+	mu sync.Mutex
+}
+
+func newArtCache(cfg config.Config) *artCache {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return &artCache{
+		dir:         filepath.Join(dir, "argus", "art"),
+		size:        cfg.ArtSize,
+		placeholder: cfg.ArtPlaceholder,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// serveCurrent serves the cover of the track that is playing right now.
+func (c *artCache) serveCurrent(w http.ResponseWriter, r *http.Request, data services.NowPlayingData) {
+	path, key, err := c.get(data.Item)
+	if err != nil {
+		if !errors.Is(err, errNoArt) {
+			log.Printf("Error loading album art: %v", err)
+		}
+		if c.placeholder == "" {
+			http.NotFound(w, r)
+			return
+		}
+		path, key = c.placeholder, "placeholder"
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The URL stays the same while the cover changes, so browsers must revalidate,
+	// but an unchanged cover only costs a 304.
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", `"`+key+`"`)
+	http.ServeContent(w, r, filepath.Base(path), info.ModTime(), file)
+}
+
+// get returns the path of the cached cover for track, fetching it first if needed.
+// Covers the player handed over directly are keyed by their contents, others by URL,
+// and both by the size they were scaled to, so changing ART_SIZE doesn't reuse old ones.
+func (c *artCache) get(track *services.Track) (path, key string, err error) {
+	hash := sha256.New()
+	switch {
+	case track == nil:
+		return "", "", errNoArt
+	case len(track.ArtData) > 0:
+		hash.Write(track.ArtData)
+	case track.ArtURL != "":
+		hash.Write([]byte(track.ArtURL))
+	default:
+		return "", "", errNoArt
+	}
+	fmt.Fprintf(hash, "\x00%d", c.size)
+	key = hex.EncodeToString(hash.Sum(nil))
+	path = filepath.Join(c.dir, key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		return path, key, nil
+	}
+
+	raw := track.ArtData
+	if len(raw) == 0 {
+		raw, err = c.fetch(track.ArtURL)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	cover, err := c.resize(raw)
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(path, cover, 0o644); err != nil {
+		return "", "", err
+	}
+	c.prune()
+	return path, key, nil
+}
+
+// fetch reads a cover from a file:// or http(s):// URL.
+func (c *artCache) fetch(artURL string) ([]byte, error) {
+	u, err := url.Parse(artURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "file":
+		file, err := os.Open(u.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return io.ReadAll(io.LimitReader(file, maxArtBytes))
+	case "http", "https":
+		resp, err := c.client.Get(artURL)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching %s: %s", artURL, resp.Status)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, maxArtBytes))
+	default:
+		return nil, fmt.Errorf("unsupported album art URL scheme %q", u.Scheme)
+	}
+}
+
+// resize scales the cover down to fit in size×size and re-encodes it as JPEG. Anything
+// that doesn't decode as an image is refused, so a file:// URL can only ever serve covers.
+func (c *artCache) resize(raw []byte) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("decoding album art: %w", err)
+	}
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, scaleDown(src, c.size), &jpeg.Options{Quality: 90}); err != nil {
+		return nil, fmt.Errorf("encoding album art: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// prune removes the oldest covers once the cache holds more than maxCachedArt.
+func (c *artCache) prune() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil || len(entries) <= maxCachedArt {
+		return
+	}
+
+	type cached struct {
+		name    string
+		modTime time.Time
+	}
+	var files []cached
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil {
+			files = append(files, cached{entry.Name(), info.ModTime()})
+		}
+	}
+	slices.SortFunc(files, func(a, b cached) int { return a.modTime.Compare(b.modTime) })
+
+	for _, file := range files[:len(files)-maxCachedArt] {
+		os.Remove(filepath.Join(c.dir, file.name))
+	}
+}
+
+// scaleDown shrinks src to fit in a size×size box by averaging the source pixels under
+// each destination pixel. Images that already fit are returned as they are.
+func scaleDown(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if size <= 0 || (w <= size && h <= size) {
+		return src
+	}
+
+	dw, dh := size, size
+	if w > h {
+		dh = max(1, h*size/w)
+	} else {
+		dw = max(1, w*size/h)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		y0, y1 := bounds.Min.Y+y*h/dh, bounds.Min.Y+(y+1)*h/dh
+		for x := range dw {
+			x0, x1 := bounds.Min.X+x*w/dw, bounds.Min.X+(x+1)*w/dw
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < max(y1, y0+1); sy++ {
+				for sx := x0; sx < max(x1, x0+1); sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(b / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
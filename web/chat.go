@@ -0,0 +1,313 @@
+package web
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"argus/bus"
+	"argus/config"
+	"argus/helix"
+)
+
+// EMOTE_URL is the CDN template for Twitch emote images.
+const EMOTE_URL = "https://static-cdn.jtvnw.net/emoticons/v2/%s/default/dark/1.0"
+
+// ChatOverlayMessage is what the chat overlay receives for each chat message.
+type ChatOverlayMessage struct {
+	ID        string         `json:"id"`
+	User      string         `json:"user"`
+	Color     string         `json:"color"`
+	Badges    []ChatBadge    `json:"badges"`
+	Fragments []ChatFragment `json:"fragments"`
+	TTLMs     int64          `json:"ttl_ms"`
+}
+
+// ChatBadge is a badge image shown before the user's name.
+type ChatBadge struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// ChatFragment is a run of plain text or a single emote within a message.
+type ChatFragment struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+	URL  string `json:"url,omitempty"`
+}
+
+// defaultNameColors are used for users who never picked a name color, like Twitch does.
+var defaultNameColors = []string{
+	"#FF0000", "#0000FF", "#008000", "#B22222", "#FF7F50", "#9ACD32", "#FF4500", "#2E8B57",
+	"#DAA520", "#D2691E", "#5F9EA0", "#1E90FF", "#FF69B4", "#8A2BE2", "#00FF7F",
+}
+
+// badgeRetry is how long a failed badge load waits before Helix is asked again.
+const badgeRetry = time.Minute
+
+// badgeCache resolves badge tags to image URLs, loading them from Helix on first use.
+// Channel badges are kept per broadcaster ID, since every joined channel has its own.
+type badgeCache struct {
+	helix *helix.Client
+	// channelID looks up the broadcaster ID of a joined channel.
+	channelID func(channel string) string
+
+	mu sync.Mutex
+	// sets holds the badges loaded so far by broadcaster ID, with the global ones under "".
+	sets map[string]map[string]string
+	// retryAt holds when a failed load may be tried again.
+	retryAt map[string]time.Time
+}
+
+func newBadgeCache(cfg config.Config, channelID func(channel string) string) *badgeCache {
+	return &badgeCache{
+		helix:     helix.NewClient(cfg.ClientID, cfg.AppAccessToken),
+		channelID: channelID,
+		sets:      make(map[string]map[string]string),
+		retryAt:   make(map[string]time.Time),
+	}
+}
+
+// broadcasterID returns the ID of the channel msg was sent in. Until EventSub has looked
+// the channel up, the room-id tag Twitch puts on chat messages is used instead.
+func (c *badgeCache) broadcasterID(msg bus.ChatMessage) string {
+	if id := c.channelID(msg.Channel); id != "" {
+		return id
+	}
+	return msg.Tags["room-id"]
+}
+
+// url returns the image URL for a badge set and version, such as "subscriber/12", in the
+// channel of broadcasterID. Channel badges override global ones, such as custom sub badges.
+func (c *badgeCache) url(broadcasterID, badge string) string {
+	if broadcasterID != "" {
+		if url := c.badges(broadcasterID)[badge]; url != "" {
+			return url
+		}
+	}
+	return c.badges("")[badge]
+}
+
+// badges returns the badges of a channel, or the global ones for "", loading them the
+// first time. Only successful loads are kept; a failed one is retried after badgeRetry.
+func (c *badgeCache) badges(broadcasterID string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if urls, ok := c.sets[broadcasterID]; ok {
+		return urls
+	}
+	if time.Now().Before(c.retryAt[broadcasterID]) {
+		return nil
+	}
+
+	var sets []helix.BadgeSet
+	var err error
+	if broadcasterID == "" {
+		sets, err = c.helix.GlobalChatBadges()
+	} else {
+		sets, err = c.helix.ChannelChatBadges(broadcasterID)
+	}
+	if err != nil {
+		if broadcasterID == "" {
+			log.Printf("Error loading global chat badges: %v", err)
+		} else {
+			log.Printf("Error loading chat badges for channel %s: %v", broadcasterID, err)
+		}
+		c.retryAt[broadcasterID] = time.Now().Add(badgeRetry)
+		return nil
+	}
+	delete(c.retryAt, broadcasterID)
+	c.sets[broadcasterID] = badgeURLs(sets)
+	return c.sets[broadcasterID]
+}
+
+// badgeURLs maps every version of sets, keyed like the badges tag, to its image.
+func badgeURLs(sets []helix.BadgeSet) map[string]string {
+	urls := make(map[string]string)
+	for _, set := range sets {
+		for _, version := range set.Versions {
+			urls[set.SetID+"/"+version.ID] = version.ImageURL1x
+		}
+	}
+	return urls
+}
+
+// toChatOverlayMessage prepares a chat message for the overlay.
+func toChatOverlayMessage(badges *badgeCache, ttl time.Duration, msg bus.ChatMessage) ChatOverlayMessage {
+	color := msg.Color
+	if color == "" {
+		color = defaultNameColor(msg.UserLogin)
+	}
+
+	out := ChatOverlayMessage{
+		ID:        msg.ID,
+		User:      msg.DisplayName,
+		Color:     color,
+		Badges:    []ChatBadge{},
+		Fragments: emoteFragments(msg.Text, msg.Tags["emotes"]),
+		TTLMs:     ttl.Milliseconds(),
+	}
+
+	broadcasterID := badges.broadcasterID(msg)
+	for badge := range strings.SplitSeq(msg.Badges, ",") {
+		if badge == "" {
+			continue
+		}
+		if url := badges.url(broadcasterID, badge); url != "" {
+			name, _, _ := strings.Cut(badge, "/")
+			out.Badges = append(out.Badges, ChatBadge{Name: name, URL: url})
+		}
+	}
+	return out
+}
+
+// defaultNameColor picks a stable color for a user without one.
+func defaultNameColor(login string) string {
+	h := fnv.New32a()
+	h.Write([]byte(login))
+	return defaultNameColors[h.Sum32()%uint32(len(defaultNameColors))]
+}
+
+// emoteRange is where one emote appears in a message, in characters.
+type emoteRange struct {
+	id         string
+	start, end int
+}
+
+// emoteFragments splits text into text and emote fragments using the emotes tag, which
+// looks like "25:0-4,12-16/1902:6-10" with inclusive character positions.
+func emoteFragments(text, emotesTag string) []ChatFragment {
+	var ranges []emoteRange
+	for emote := range strings.SplitSeq(emotesTag, "/") {
+		id, positions, ok := strings.Cut(emote, ":")
+		if !ok {
+			continue
+		}
+		for position := range strings.SplitSeq(positions, ",") {
+			startStr, endStr, ok := strings.Cut(position, "-")
+			if !ok {
+				continue
+			}
+			start, err1 := strconv.Atoi(startStr)
+			end, err2 := strconv.Atoi(endStr)
+			if err1 != nil || err2 != nil || start > end {
+				continue
+			}
+			ranges = append(ranges, emoteRange{id: id, start: start, end: end})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+
+	runes := []rune(text)
+	fragments := []ChatFragment{}
+	pos := 0
+	for _, r := range ranges {
+		if r.start < pos || r.end >= len(runes) {
+			// Overlapping or out of range; the tag doesn't match this text.
+			continue
+		}
+		if r.start > pos {
+			fragments = append(fragments, ChatFragment{Type: "text", Text: string(runes[pos:r.start])})
+		}
+		fragments = append(fragments, ChatFragment{
+			Type: "emote",
+			Text: string(runes[r.start : r.end+1]),
+			URL:  fmt.Sprintf(EMOTE_URL, r.id),
+		})
+		pos = r.end + 1
+	}
+	if pos < len(runes) {
+		fragments = append(fragments, ChatFragment{Type: "text", Text: string(runes[pos:])})
+	}
+	return fragments
+}
+
+func chatHandler(w http.ResponseWriter, r *http.Request) {
+	html := `
+		<!DOCTYPE html>
+		<html>
+		<head>
+			<title>Chat</title>
+			<script src="https://cdn.jsdelivr.net/npm/@tailwindcss/browser@4"></script>
+			<style>
+				.message {
+					transition: opacity 0.5s ease;
+				}
+				.message.expired {
+					opacity: 0;
+				}
+				.message img {
+					display: inline;
+					vertical-align: middle;
+				}
+			</style>
+		</head>
+		<body class="bg-transparent text-white flex flex-col justify-end min-h-screen overflow-hidden p-4">
+			<div id="chat" class="flex flex-col gap-2 text-lg"></div>
+			<script>
+				const chatEl = document.getElementById('chat');
+				const maxMessages = 50;
+
+				function render(msg) {
+					const el = document.createElement('div');
+					el.className = 'message px-3 py-2 rounded-lg';
+					el.style.backgroundColor = 'rgba(31, 41, 55, 0.7)';
+
+					for (const badge of msg.badges) {
+						const img = document.createElement('img');
+						img.src = badge.url;
+						img.alt = badge.name;
+						img.className = 'mr-1';
+						el.appendChild(img);
+					}
+
+					const name = document.createElement('span');
+					name.className = 'font-bold';
+					name.style.color = msg.color;
+					name.textContent = msg.user;
+					el.appendChild(name);
+					el.appendChild(document.createTextNode(': '));
+
+					for (const fragment of msg.fragments) {
+						if (fragment.type === 'emote') {
+							const img = document.createElement('img');
+							img.src = fragment.url;
+							img.alt = fragment.text;
+							el.appendChild(img);
+						} else {
+							el.appendChild(document.createTextNode(fragment.text));
+						}
+					}
+					return el;
+				}
+
+				const source = new EventSource('/chat/events' + window.location.search);
+				source.onmessage = (event) => {
+					const msg = JSON.parse(event.data);
+					const el = render(msg);
+					chatEl.appendChild(el);
+
+					while (chatEl.children.length > maxMessages) {
+						chatEl.removeChild(chatEl.firstChild);
+					}
+
+					if (msg.ttl_ms > 0) {
+						setTimeout(() => {
+							el.classList.add('expired');
+							setTimeout(() => el.remove(), 500);
+						}, msg.ttl_ms);
+					}
+				};
+			</script>
+		</body>
+		</html>
+	`
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprint(w, html)
+}
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	html := `
+		<!DOCTYPE html>
+		<html>
+		<head>
+			<title>Song History</title>
+			<script src="https://cdn.jsdelivr.net/npm/@tailwindcss/browser@4"></script>
+		</head>
+		<body class="bg-gray-900 text-white min-h-screen p-8">
+			<h1 class="text-3xl font-bold mb-6">Song History</h1>
+			<table class="w-full text-left">
+				<thead class="text-gray-400 uppercase text-sm">
+					<tr>
+						<th class="py-2 pr-4">Time</th>
+						<th class="py-2 pr-4">Title</th>
+						<th class="py-2 pr-4">Artist</th>
+						<th class="py-2 pr-4">Album</th>
+					</tr>
+				</thead>
+				<tbody id="history"></tbody>
+			</table>
+			<script>
+				const historyEl = document.getElementById('history');
+
+				function cell(text) {
+					const td = document.createElement('td');
+					td.className = 'py-2 pr-4 border-t border-gray-700';
+					td.textContent = text;
+					return td;
+				}
+
+				async function loadHistory() {
+					const entries = await (await fetch('/history?limit=200')).json();
+					historyEl.replaceChildren(...entries.map(entry => {
+						const row = document.createElement('tr');
+						const title = cell(entry.name);
+						if (entry.url && entry.url.startsWith('http')) {
+							const link = document.createElement('a');
+							link.href = entry.url;
+							link.className = 'underline';
+							link.textContent = entry.name;
+							title.replaceChildren(link);
+						}
+						row.append(cell(new Date(entry.time).toLocaleString()), title, cell(entry.artist), cell(entry.album || ''));
+						return row;
+					}));
+				}
+
+				// Reload whenever a different song starts.
+				let currentTitle = null;
+				const source = new EventSource('/now-playing/events');
+				source.onmessage = (event) => {
+					const data = JSON.parse(event.data);
+					const title = data.item ? data.item.name : null;
+					if (title !== currentTitle) {
+						currentTitle = title;
+						loadHistory();
+					}
+				};
+				loadHistory();
+			</script>
+		</body>
+		</html>
+	`
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprint(w, html)
+}
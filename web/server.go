@@ -1,16 +1,27 @@
 package web
 
 import (
+	"argus/bus"
 	"argus/config"
+	"argus/health"
+	"argus/history"
 	"argus/services"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 )
 
-// StartServer starts the web server.
-func StartServer(cfg config.Config) {
+// StartServer starts the web server. Overlays that show live activity are fed from b,
+// the now playing widget reads from the shared poller, and songs backs /history.
+// channelID returns the broadcaster ID of a joined channel, for its chat badges.
+func StartServer(cfg config.Config, b *bus.Bus, poller *services.Poller, songs *history.History, channelID func(channel string) string) {
+	alertStyles, err := newAlertStyles(cfg)
+	if err != nil {
+		log.Fatalf("Invalid alert configuration: %v", err)
+	}
+
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		html := `
 			<!DOCTYPE html>
@@ -59,32 +70,61 @@ func StartServer(cfg config.Config) {
 				</style>
 			</head>
 			<body class="bg-transparent text-white flex items-center justify-center min-h-screen">
-				<div id="spotify-widget" class="flex flex-col p-6 rounded-xl shadow-lg w-full max-w-lg" style="background-color: rgba(31, 41, 55, 0.7);">
-					<p id="artist-name" class="text-xl text-gray-200 font-semibold mb-1">Loading Artist...</p>
-					<div id="title-container" class="relative z-50 overflow-clip whitespace-nowrap text-lg font-bold animated-title mb-4">
-						<h2 id="song-title" class="text-3xl font-bold text-white"><span>Loading Song...</span></h2>
-					</div>
-					<div class="w-full h-2 bg-gray-500 rounded-full">
-						<div id="progress-bar" class="h-full bg-red-600 rounded-full transition-all duration-100" style="width: 0%;"></div>
+				<div id="spotify-widget" class="flex items-center gap-4 p-6 rounded-xl shadow-lg w-full max-w-lg transition-opacity" style="background-color: rgba(31, 41, 55, 0.7);">
+					<img id="album-art" class="w-20 h-20 rounded-lg object-cover shrink-0" style="display: none;" alt="">
+					<div class="flex flex-col min-w-0 flex-1">
+						<p id="artist-name" class="text-xl text-gray-200 font-semibold mb-1">Loading Artist...</p>
+						<div id="title-container" class="relative z-50 overflow-clip whitespace-nowrap text-lg font-bold animated-title">
+							<h2 id="song-title" class="text-3xl font-bold text-white"><span>Loading Song...</span></h2>
+						</div>
+						<p id="album-name" class="text-sm text-gray-300 truncate mb-4"></p>
+						<div class="flex items-center gap-2">
+							<span id="paused-label" class="text-xs uppercase tracking-wide text-gray-300" style="display: none;">Paused</span>
+							<div class="w-full h-2 bg-gray-500 rounded-full">
+								<div id="progress-bar" class="h-full bg-red-600 rounded-full" style="width: 0%;"></div>
+							</div>
+						</div>
 					</div>
 				</div>
 				<script>
-					function updateNowPlaying() {
-						fetch('/now-playing')
-						.then(response => response.json())
-						.then(data => {
-							const widget = document.getElementById('spotify-widget');
-							const songTitleEl = document.getElementById('song-title');
-							const artistNameEl = document.getElementById('artist-name');
-							const progressBarEl = document.getElementById('progress-bar');
-							const titleContainer = document.getElementById('title-container');
-
-							if (data && data.is_playing) {
-								widget.style.display = 'flex';
-								artistNameEl.textContent = data.item.artists[0].name;
+					let currentTitle = null;
+
+					// progress is the last snapshot's position, and receivedAt when it arrived. The
+					// bar is moved from there every frame at the snapshot's playback rate.
+					let progress = null;
+
+					const albumArt = document.getElementById('album-art');
+					albumArt.onload = () => albumArt.style.display = 'block';
+					albumArt.onerror = () => albumArt.style.display = 'none';
+
+					function updateNowPlaying(data) {
+						const widget = document.getElementById('spotify-widget');
+						const songTitleEl = document.getElementById('song-title');
+						const artistNameEl = document.getElementById('artist-name');
+						const titleContainer = document.getElementById('title-container');
+						const albumNameEl = document.getElementById('album-name');
+						const albumArtEl = document.getElementById('album-art');
+						const pausedLabelEl = document.getElementById('paused-label');
+
+						if (data && data.item && (data.status === 'playing' || data.status === 'paused')) {
+							widget.style.display = 'flex';
+							widget.style.opacity = data.status === 'paused' ? '0.6' : '1';
+							pausedLabelEl.style.display = data.status === 'paused' ? 'inline' : 'none';
+							artistNameEl.textContent = data.item.artists.map(artist => artist.name).join(', ');
+							albumNameEl.textContent = data.item.album || '';
+
+							// Covers go through Argus, which can read file:// art and caches remote art.
+							// The query string only busts the browser cache when the cover changes.
+							const artSrc = '/art/current?u=' + encodeURIComponent(data.item.art_url || data.item.art_id || '');
+							if (albumArtEl.getAttribute('src') !== artSrc) {
+								albumArtEl.src = artSrc;
+							}
+
+							// Only restart the scroll animation when the song actually changes.
+							if (data.item.name !== currentTitle) {
+								currentTitle = data.item.name;
 								songTitleEl.textContent = data.item.name;
-								
-								// Reset animation state
+
 								songTitleEl.classList.remove('animate');
 								songTitleEl.style.transform = 'translate(0)';
 								songTitleEl.style.left = '0%';
@@ -92,25 +132,41 @@ func StartServer(cfg config.Config) {
 								if (songTitleEl.scrollWidth > titleContainer.clientWidth) {
 									songTitleEl.classList.add('animate');
 								}
-								
-								const progressMs = data.progress_ms;
-								const durationMs = data.item.duration_ms;
-
-                                if (durationMs > 0) {
-                                    const progressPercentage = (progressMs / durationMs) * 100;
-                                    progressBarEl.style.width = progressPercentage + '%';
-                                } else {
-                                    progressBarEl.style.width = '0%';
-                                }
-							} else {
-								widget.style.display = 'none';
 							}
-						})
-						.catch(error => console.error('Error fetching data:', error));
+
+							// Account for the time the snapshot spent in flight, but don't trust a
+							// clock that is far off from the server's.
+							const latency = data.timestamp ? Math.min(Math.max(Date.now() - data.timestamp, 0), 2000) : 0;
+							progress = {
+								ms: (data.progress_ms || 0) + latency * (data.playback_rate || 0),
+								durationMs: data.item.duration_ms || 0,
+								rate: data.playback_rate || 0,
+								receivedAt: performance.now(),
+							};
+						} else {
+							currentTitle = null;
+							progress = null;
+							widget.style.display = 'none';
+						}
 					}
-					
-					updateNowPlaying();
-					setInterval(updateNowPlaying, 1000); 
+
+					function drawProgress(now) {
+						const progressBarEl = document.getElementById('progress-bar');
+						if (progress && progress.durationMs > 0) {
+							const ms = progress.ms + (now - progress.receivedAt) * progress.rate;
+							const progressPercentage = Math.min(ms / progress.durationMs, 1) * 100;
+							progressBarEl.style.width = progressPercentage + '%';
+						} else {
+							progressBarEl.style.width = '0%';
+						}
+						requestAnimationFrame(drawProgress);
+					}
+					requestAnimationFrame(drawProgress);
+
+					// The server pushes a snapshot on connect, on every track change and as progress ticks.
+					// EventSource reconnects on its own if Argus restarts.
+					const source = new EventSource('/now-playing/events');
+					source.onmessage = (event) => updateNowPlaying(JSON.parse(event.data));
 				</script>
 			</body>
 			</html>
@@ -120,17 +176,86 @@ func StartServer(cfg config.Config) {
 	})
 
 	http.HandleFunc("/now-playing", func(w http.ResponseWriter, r *http.Request) {
-		nowPlayingService := services.NewNowPlayingService()
-		data, err := nowPlayingService.GetNowPlayingInfo()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(poller.Current())
+	})
+
+	http.HandleFunc("/now-playing/events", func(w http.ResponseWriter, r *http.Request) {
+		streamEvents(w, r, b, poller.Current(), func(event bus.Event) (any, bool) {
+			switch e := event.(type) {
+			case bus.TrackChanged:
+				return e.Data, true
+			case bus.TrackProgress:
+				return e.Data, true
+			}
+			return nil, false
+		})
+	})
+
+	art := newArtCache(cfg)
+	http.HandleFunc("/art/current", func(w http.ResponseWriter, r *http.Request) {
+		art.serveCurrent(w, r, poller.Current())
+	})
+
+	http.HandleFunc("/history", func(w http.ResponseWriter, r *http.Request) {
+		limit := 50
+		if value := r.URL.Query().Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
 
 		w.Header().Set("Content-Type", "application/json")
-		if err != nil {
-			log.Printf("Error getting now playing info: %v", err)
-			json.NewEncoder(w).Encode(services.NowPlayingData{IsPlaying: false})
-			return
+		json.NewEncoder(w).Encode(songs.Recent(limit))
+	})
+
+	http.HandleFunc("/history/page", historyHandler)
+
+	http.HandleFunc("/alerts", alertsHandler)
+
+	http.HandleFunc("/alerts/events", func(w http.ResponseWriter, r *http.Request) {
+		streamEvents(w, r, b, nil, func(event bus.Event) (any, bool) {
+			// Activity in other channels we watch is not ours to celebrate on stream.
+			if channel := bus.ChannelOf(event); channel != "" && channel != cfg.Channel {
+				return nil, false
+			}
+			return toAlert(alertStyles, event)
+		})
+	})
+
+	http.HandleFunc("/chat", chatHandler)
+
+	badges := newBadgeCache(cfg, channelID)
+	http.HandleFunc("/chat/events", func(w http.ResponseWriter, r *http.Request) {
+		// Only our own channel is shown unless the overlay asks for another one, or "all".
+		channel := cfg.Channel
+		if name := r.URL.Query().Get("channel"); name != "" {
+			channel = config.ChannelName(name)
 		}
 
-		json.NewEncoder(w).Encode(data)
+		streamEvents(w, r, b, nil, func(event bus.Event) (any, bool) {
+			msg, ok := event.(bus.ChatMessage)
+			if !ok || (channel != "#all" && msg.Channel != channel) {
+				return nil, false
+			}
+			return toChatOverlayMessage(badges, cfg.ChatOverlayTTL, msg), true
+		})
+	})
+
+	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		components := health.Snapshot()
+
+		w.Header().Set("Content-Type", "application/json")
+		for _, status := range components {
+			if !status.Healthy() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				break
+			}
+		}
+		json.NewEncoder(w).Encode(components)
 	})
 
 	if cfg.ShowLogs {
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"argus/bus"
+)
+
+// heartbeatInterval keeps idle Server-Sent Event streams from being closed by browsers and proxies.
+const heartbeatInterval = 15 * time.Second
+
+// streamEvents sends bus events to the client as Server-Sent Events until it disconnects.
+// initial, when not nil, is sent first so the page can render before the next event.
+// convert picks the events the stream cares about and shapes them for the browser.
+func streamEvents(w http.ResponseWriter, r *http.Request, b *bus.Bus, initial any, convert func(bus.Event) (any, bool)) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := b.Subscribe(64)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	if initial != nil {
+		if err := writeEvent(w, initial); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case event := <-events:
+			data, ok := convert(event)
+			if !ok {
+				continue
+			}
+			if err := writeEvent(w, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes a single Server-Sent Event with data encoded as JSON.
+func writeEvent(w http.ResponseWriter, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error encoding event: %v", err)
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", payload)
+	return err
+}